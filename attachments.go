@@ -0,0 +1,177 @@
+// Copyright 2018 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package issues // import "mellium.im/issues"
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// uploadAttachments uploads every attachment on issue to sink (if set),
+// returning the issue with its own and its comments' Markdown bodies
+// rewritten to point at the new URLs.
+//
+// Attachments with no Open func (for example ones that already live at a
+// URL on the destination tracker) are left as-is.
+func uploadAttachments(ctx context.Context, sink AttachmentSink, issue Issue) (Issue, error) {
+	if sink == nil {
+		return issue, nil
+	}
+
+	rewrites := make(map[string]string)
+	for i, a := range issue.Attachments {
+		if a.Open == nil {
+			continue
+		}
+		newURL, err := uploadAttachment(ctx, sink, a)
+		if err != nil {
+			return issue, fmt.Errorf("issues: error uploading attachment %q: %w", a.Name, err)
+		}
+		rewrites[a.URL] = newURL
+		rewrites[a.Name] = newURL
+		issue.Attachments[i].URL = newURL
+	}
+	if len(rewrites) == 0 {
+		return issue, nil
+	}
+
+	issue.Body = rewriteAttachmentRefs(issue.Body, rewrites)
+	for i, c := range issue.Comments {
+		issue.Comments[i].Body = rewriteAttachmentRefs(c.Body, rewrites)
+	}
+	return issue, nil
+}
+
+func uploadAttachment(ctx context.Context, sink AttachmentSink, a Attachment) (string, error) {
+	rc, err := a.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	return sink.Upload(ctx, a.Name, rc)
+}
+
+// rewriteAttachmentRefs replaces every occurrence of an old attachment
+// name or URL in body with its new URL, so that Markdown references such
+// as `![schema.png](attachments/5/schema.png)` keep working once the
+// attachment has moved. The replacement is done in a single pass with
+// longer keys tried first, so an old URL is replaced whole rather than
+// just the name inside it, and a new URL that contains an old name is not
+// rewritten again.
+func rewriteAttachmentRefs(body string, rewrites map[string]string) string {
+	olds := make([]string, 0, len(rewrites))
+	for old, new := range rewrites {
+		if old == "" || old == new {
+			continue
+		}
+		olds = append(olds, old)
+	}
+	sort.Slice(olds, func(i, j int) bool { return len(olds[i]) > len(olds[j]) })
+	pairs := make([]string, 0, 2*len(olds))
+	for _, old := range olds {
+		pairs = append(pairs, old, rewrites[old])
+	}
+	return strings.NewReplacer(pairs...).Replace(body)
+}
+
+// ReleaseAttachmentSink uploads attachments as assets on a GitHub release,
+// creating the release if it doesn't already exist.
+type ReleaseAttachmentSink struct {
+	Client *github.Client
+	Owner  string
+	Repo   string
+	// Tag is the tag of the release that attachments are uploaded to.
+	Tag string
+
+	releaseID int64
+}
+
+// NewReleaseAttachmentSink returns an AttachmentSink that uploads
+// attachments as assets on the release tagged tag in owner/repo, creating
+// it if necessary.
+func NewReleaseAttachmentSink(client *github.Client, owner, repo, tag string) *ReleaseAttachmentSink {
+	return &ReleaseAttachmentSink{Client: client, Owner: owner, Repo: repo, Tag: tag}
+}
+
+// Upload implements AttachmentSink.
+//
+// UploadReleaseAsset requires an *os.File, so r is spooled to a temporary
+// file before being uploaded.
+func (s *ReleaseAttachmentSink) Upload(ctx context.Context, name string, r io.Reader) (string, error) {
+	if s.releaseID == 0 {
+		release, _, err := s.Client.Repositories.GetReleaseByTag(ctx, s.Owner, s.Repo, s.Tag)
+		if err != nil {
+			release, _, err = s.Client.Repositories.CreateRelease(ctx, s.Owner, s.Repo, &github.RepositoryRelease{
+				TagName: &s.Tag,
+				Name:    &s.Tag,
+			})
+			if err != nil {
+				return "", fmt.Errorf("issues: error creating release %q: %w", s.Tag, err)
+			}
+		}
+		s.releaseID = release.GetID()
+	}
+
+	f, err := ioutil.TempFile("", "issues-attachment-")
+	if err != nil {
+		return "", fmt.Errorf("issues: error creating temp file for %q: %w", name, err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := io.Copy(f, r); err != nil {
+		return "", fmt.Errorf("issues: error spooling attachment %q: %w", name, err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("issues: error rewinding attachment %q: %w", name, err)
+	}
+
+	asset, _, err := s.Client.Repositories.UploadReleaseAsset(ctx, s.Owner, s.Repo, s.releaseID, &github.UploadOptions{
+		Name: name,
+	}, f)
+	if err != nil {
+		return "", fmt.Errorf("issues: error uploading asset %q: %w", name, err)
+	}
+	return asset.GetBrowserDownloadURL(), nil
+}
+
+// HTTPAttachmentSink uploads attachments to a configurable HTTP endpoint,
+// for example an S3-compatible bucket that accepts a PUT of the raw file
+// contents at <BaseURL>/<name>.
+type HTTPAttachmentSink struct {
+	Client  *http.Client
+	BaseURL string
+}
+
+// NewHTTPAttachmentSink returns an AttachmentSink that PUTs attachment
+// contents to baseURL+"/"+name and returns that same URL.
+func NewHTTPAttachmentSink(baseURL string) *HTTPAttachmentSink {
+	return &HTTPAttachmentSink{Client: http.DefaultClient, BaseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// Upload implements AttachmentSink.
+func (s *HTTPAttachmentSink) Upload(ctx context.Context, name string, r io.Reader) (string, error) {
+	u := s.BaseURL + "/" + name
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u, r)
+	if err != nil {
+		return "", fmt.Errorf("issues: error building attachment upload request: %w", err)
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("issues: error uploading attachment to %q: %w", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("issues: attachment upload to %q failed: %s", u, resp.Status)
+	}
+	return u, nil
+}
@@ -0,0 +1,381 @@
+// Copyright 2018 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package issues // import "mellium.im/issues"
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// dbFileName is the name of the JSON file inside of a Bitbucket issue
+// export that contains the actual issue data.
+const dbFileName = "db-1.0.json"
+
+// BitbucketDownloader is a Downloader that reads issues out of a Bitbucket
+// issue export zip file.
+//
+// Attachments are read directly out of the zip file as they're requested,
+// so the BitbucketDownloader must be closed once the migration is done.
+type BitbucketDownloader struct {
+	export export
+	zip    *zip.ReadCloser
+
+	// Users, if set, maps Bitbucket usernames to GitHub identities so that
+	// assignees, @mentions, and issue/comment attribution point at real
+	// GitHub users instead of the raw Bitbucket username.
+	Users *UserMap
+}
+
+// NewBitbucketDownloader opens the Bitbucket issue export zip file at path
+// and parses its contents.
+//
+// The zip file can be obtained by visiting a repo's settings on Bitbucket
+// and choosing "Import & export" from the "issues" section.
+func NewBitbucketDownloader(path string) (*BitbucketDownloader, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("issues: error opening %q: %w", path, err)
+	}
+
+	var f *zip.File
+	for _, zf := range r.File {
+		if zf.Name == dbFileName {
+			f = zf
+			break
+		}
+	}
+	if f == nil {
+		r.Close()
+		return nil, fmt.Errorf("issues: %q not found in %q", dbFileName, path)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("issues: error opening %q in %q: %w", dbFileName, path, err)
+	}
+	defer rc.Close()
+
+	d := &BitbucketDownloader{zip: r}
+	if err := json.NewDecoder(rc).Decode(&d.export); err != nil {
+		r.Close()
+		return nil, fmt.Errorf("issues: error decoding %q in %q: %w", dbFileName, path, err)
+	}
+	return d, nil
+}
+
+// Close closes the underlying zip file.
+func (d *BitbucketDownloader) Close() error {
+	return d.zip.Close()
+}
+
+// ListIssues implements Downloader.
+func (d *BitbucketDownloader) ListIssues(ctx context.Context) ([]Issue, error) {
+	milestoneIDs := make(map[string]int)
+	for i, name := range d.milestoneTitles() {
+		milestoneIDs[name] = i + 1
+	}
+
+	issues := make([]Issue, 0, len(d.export.Issues))
+	for _, src := range d.export.Issues {
+		var milestone *Milestone
+		if name := milestoneName(src.Milestone); name != "" {
+			milestone = &Milestone{ID: milestoneIDs[name], Title: name}
+		}
+
+		var labels []string
+		if src.Priority != "" {
+			labels = append(labels, src.Priority)
+		}
+		if src.Kind != "" {
+			labels = append(labels, src.Kind)
+		}
+		if src.Component != nil && *src.Component != "" {
+			labels = append(labels, *src.Component)
+		}
+		// Bitbucket statuses are more fine grained than most trackers'
+		// open/closed state, so keep the original status around as a label.
+		if src.Status != "" {
+			labels = append(labels, src.Status)
+		}
+
+		var assignees []string
+		if d.Users != nil && src.Assignee != nil && *src.Assignee != "" {
+			if handle, ok := d.Users.GitHub(*src.Assignee); ok {
+				assignees = append(assignees, handle)
+			}
+		}
+
+		issues = append(issues, Issue{
+			ID:    src.ID,
+			Title: src.Title,
+			Body: fmt.Sprintf(`by %s:
+
+---
+
+%s`, d.attribute(src.Reporter), RewriteMentions(src.Content, d.Users)),
+			Author:    src.Reporter,
+			Assignees: assignees,
+			Labels:    labels,
+			Milestone: milestone,
+			Closed:    bitbucketClosed(src.Status),
+			CreatedOn: src.CreatedOn,
+			UpdatedOn: src.UpdatedOn,
+		})
+	}
+	return issues, nil
+}
+
+// attribute formats the "by <user>:" prefix used on every issue and
+// comment body, preferring an @mention of the mapped GitHub handle over
+// the raw Bitbucket username so GitHub renders and notifies it like any
+// other attribution.
+func (d *BitbucketDownloader) attribute(user string) string {
+	if d.Users != nil {
+		if handle, ok := d.Users.GitHub(user); ok {
+			return "@" + handle
+		}
+	}
+	return "**" + user + "**"
+}
+
+// bitbucketClosed reports whether a Bitbucket issue status maps to a closed
+// issue on most destination trackers.
+func bitbucketClosed(status string) bool {
+	switch status {
+	case "resolved", "closed", "invalid", "wontfix", "duplicate":
+		return true
+	}
+	return false
+}
+
+// ListComments implements Downloader.
+//
+// Both the export's own comments and its logs (a record of field changes
+// such as status transitions) are translated into comments, matching the
+// "by <user>:" attribution already used for the issue body itself. Log
+// entries become a synthetic comment describing the change so that the
+// history isn't lost even though most destination trackers have no
+// equivalent of Bitbucket's changelog.
+func (d *BitbucketDownloader) ListComments(ctx context.Context, issueID int) ([]Comment, error) {
+	var comments []Comment
+	for _, c := range d.export.Comments {
+		if c.Issue != issueID || c.Content == nil || strings.TrimSpace(*c.Content) == "" {
+			continue
+		}
+		comments = append(comments, Comment{
+			Author: c.User,
+			Body: fmt.Sprintf(`by %s:
+
+---
+
+%s`, d.attribute(c.User), RewriteMentions(*c.Content, d.Users)),
+			CreatedOn: c.CreatedOn,
+		})
+	}
+	for _, l := range d.export.Logs {
+		if l.Issue != issueID {
+			continue
+		}
+		comments = append(comments, Comment{
+			Author:    l.User,
+			Body:      fmt.Sprintf("%s changed %s from %q to %q", d.attribute(l.User), l.Field, l.ChangedFrom, l.ChangedTo),
+			CreatedOn: l.CreatedOn,
+		})
+	}
+	return comments, nil
+}
+
+// ListLabels implements Downloader.
+//
+// Bitbucket exports don't have a distinct concept of labels; the labels
+// used come from the per-issue priority, kind, component, and status
+// instead, so ListLabels always returns no labels. Reconcile derives the
+// labels to create from the issues themselves.
+func (d *BitbucketDownloader) ListLabels(ctx context.Context) ([]Label, error) {
+	return nil, nil
+}
+
+// ListMilestones implements Downloader.
+func (d *BitbucketDownloader) ListMilestones(ctx context.Context) ([]Milestone, error) {
+	titles := d.milestoneTitles()
+	milestones := make([]Milestone, 0, len(titles))
+	for i, name := range titles {
+		milestones = append(milestones, Milestone{ID: i + 1, Title: name})
+	}
+	return milestones, nil
+}
+
+// milestoneTitles returns the title of every milestone in the export, in
+// the same order ListMilestones returns them in, so that ListIssues can
+// assign each one the same stable, non-zero ID (its 1-based position here)
+// that Reconcile will see from ListMilestones.
+func (d *BitbucketDownloader) milestoneTitles() []string {
+	var titles []string
+	for _, raw := range d.export.Milestones {
+		if name := milestoneName(raw); name != "" {
+			titles = append(titles, name)
+		}
+	}
+	return titles
+}
+
+// milestoneName extracts a milestone's name from the loosely-typed field a
+// Bitbucket export uses both for the top-level milestones list and for the
+// milestone an individual issue references.
+func milestoneName(raw interface{}) string {
+	switch v := raw.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		name, _ := v["name"].(string)
+		return name
+	default:
+		return ""
+	}
+}
+
+// attachmentPrefix is the directory inside of a Bitbucket export zip that
+// holds the attachment contents for a given issue.
+func attachmentPrefix(issueID int) string {
+	return "attachments/" + strconv.Itoa(issueID) + "/"
+}
+
+// ListAttachments implements Downloader.
+//
+// Attachment metadata in a Bitbucket export only tells you which issue an
+// attachment belongs to, not the original comment (if any) it was added
+// to, so every attachment is returned against its issue.
+func (d *BitbucketDownloader) ListAttachments(ctx context.Context, issueID int) ([]Attachment, error) {
+	prefix := attachmentPrefix(issueID)
+	var attachments []Attachment
+	for _, zf := range d.zip.File {
+		if !strings.HasPrefix(zf.Name, prefix) || zf.FileInfo().IsDir() {
+			continue
+		}
+		zf := zf
+		attachments = append(attachments, Attachment{
+			Name: strings.TrimPrefix(zf.Name, prefix),
+			URL:  zf.Name,
+			Open: func() (io.ReadCloser, error) {
+				return zf.Open()
+			},
+		})
+	}
+	return attachments, nil
+}
+
+// BitbucketExporter is an Exporter that writes issues out to a plain JSON
+// file in the same shape as the db-1.0.json file found inside a Bitbucket
+// export zip, so that issues created on another tracker can be fed back
+// into Bitbucket-shaped tooling.
+//
+// Unlike BitbucketDownloader, BitbucketExporter reads and writes a bare
+// JSON file rather than a zip, since there's no standard way to turn an
+// export back into something Bitbucket's own importer accepts.
+type BitbucketExporter struct {
+	// Path is the JSON file that issues are appended to. If it already
+	// exists, it's parsed first and new issues are merged in; otherwise a
+	// new file is started.
+	Path string
+}
+
+// NewBitbucketExporter returns a BitbucketExporter that exports issues to
+// the JSON file at path.
+func NewBitbucketExporter(path string) *BitbucketExporter {
+	return &BitbucketExporter{Path: path}
+}
+
+// ExportIssues implements Exporter.
+func (e *BitbucketExporter) ExportIssues(ctx context.Context, issues []Issue) ([]int, error) {
+	exp, err := e.load()
+	if err != nil {
+		return nil, err
+	}
+
+	nextID := 1
+	for _, src := range exp.Issues {
+		if src.ID >= nextID {
+			nextID = src.ID + 1
+		}
+	}
+
+	ids := make([]int, len(issues))
+	for i, issue := range issues {
+		id := nextID
+		nextID++
+		ids[i] = id
+
+		exp.Issues = append(exp.Issues, bitbucketIssue{
+			Status:    bitbucketStatus(issue.Closed),
+			Title:     issue.Title,
+			Reporter:  issue.Author,
+			Content:   issue.Body,
+			CreatedOn: issue.CreatedOn,
+			UpdatedOn: issue.UpdatedOn,
+			ID:        id,
+		})
+		for _, c := range issue.Comments {
+			content := c.Body
+			exp.Comments = append(exp.Comments, bitbucketComment{
+				Content:   &content,
+				CreatedOn: c.CreatedOn,
+				User:      c.Author,
+				Issue:     id,
+			})
+		}
+	}
+
+	if err := e.save(exp); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (e *BitbucketExporter) load() (export, error) {
+	f, err := os.Open(e.Path)
+	if os.IsNotExist(err) {
+		return export{}, nil
+	} else if err != nil {
+		return export{}, fmt.Errorf("issues: error opening %q: %w", e.Path, err)
+	}
+	defer f.Close()
+
+	var exp export
+	if err := json.NewDecoder(f).Decode(&exp); err != nil {
+		return export{}, fmt.Errorf("issues: error decoding %q: %w", e.Path, err)
+	}
+	return exp, nil
+}
+
+func (e *BitbucketExporter) save(exp export) error {
+	f, err := os.Create(e.Path)
+	if err != nil {
+		return fmt.Errorf("issues: error creating %q: %w", e.Path, err)
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(exp); err != nil {
+		return fmt.Errorf("issues: error encoding %q: %w", e.Path, err)
+	}
+	return nil
+}
+
+// bitbucketStatus maps a generic open/closed Issue back to a Bitbucket
+// status, the reverse of bitbucketClosed.
+func bitbucketStatus(closed bool) string {
+	if closed {
+		return "resolved"
+	}
+	return "new"
+}
@@ -0,0 +1,58 @@
+// Copyright 2018 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package issues
+
+import (
+	"context"
+	"testing"
+)
+
+func TestBitbucketListIssuesAttachesMilestone(t *testing.T) {
+	d := &BitbucketDownloader{export: export{
+		Milestones: []interface{}{
+			map[string]interface{}{"name": "v1.0"},
+			map[string]interface{}{"name": "v2.0"},
+		},
+		Issues: []bitbucketIssue{
+			{ID: 1, Title: "no milestone"},
+			{ID: 2, Title: "first milestone", Milestone: map[string]interface{}{"name": "v1.0"}},
+			{ID: 3, Title: "second milestone", Milestone: map[string]interface{}{"name": "v2.0"}},
+		},
+	}}
+
+	milestones, err := d.ListMilestones(context.Background())
+	if err != nil {
+		t.Fatalf("ListMilestones() = %v", err)
+	}
+	idByTitle := make(map[string]int)
+	for _, m := range milestones {
+		if m.ID == 0 {
+			t.Errorf("ListMilestones() returned milestone %q with zero ID", m.Title)
+		}
+		idByTitle[m.Title] = m.ID
+	}
+	if idByTitle["v1.0"] == idByTitle["v2.0"] {
+		t.Fatalf("ListMilestones() gave v1.0 and v2.0 the same ID %d", idByTitle["v1.0"])
+	}
+
+	issues, err := d.ListIssues(context.Background())
+	if err != nil {
+		t.Fatalf("ListIssues() = %v", err)
+	}
+	byID := make(map[int]Issue)
+	for _, issue := range issues {
+		byID[issue.ID] = issue
+	}
+
+	if m := byID[1].Milestone; m != nil {
+		t.Errorf("issue 1 Milestone = %+v, want nil", m)
+	}
+	if m := byID[2].Milestone; m == nil || m.ID != idByTitle["v1.0"] || m.Title != "v1.0" {
+		t.Errorf("issue 2 Milestone = %+v, want {ID: %d, Title: v1.0}", m, idByTitle["v1.0"])
+	}
+	if m := byID[3].Milestone; m == nil || m.ID != idByTitle["v2.0"] || m.Title != "v2.0" {
+		t.Errorf("issue 3 Milestone = %+v, want {ID: %d, Title: v2.0}", m, idByTitle["v2.0"])
+	}
+}
@@ -0,0 +1,370 @@
+// Copyright 2018 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+// The issues command migrates issues between issue trackers.
+//
+// For more information try:
+//
+//	issues -help
+package main // import "mellium.im/issues/cmd/issues"
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"mellium.im/issues"
+)
+
+func usage(flags *flag.FlagSet) {
+	fmt.Fprintf(flags.Output(), `Usage of %s:
+
+	issues [options] -src=TYPE -dst=TYPE src dst
+	issues export [options] -src=TYPE -dst=TYPE src dst
+
+The first form imports issues from src into dst. The "export" subcommand
+instead reads newly created or updated issues back out of src (normally a
+tracker such as GitHub that issues were previously imported into) and
+writes them to dst in whatever shape -dst supports exporting to, today
+only a Bitbucket-style db-1.0.json file.
+
+Supported values for -src: bitbucket, gitlab, gitea, github (export only)
+Supported values for -dst: github, gitea, jira, bitbucket (export only)
+
+For bitbucket, src is the path to a Bitbucket issue export zip file, which
+can be obtained by visiting a repo's settings on Bitbucket and choosing
+"Import & export" from the "issues" section. For every other type, src and
+dst are of the form "owner/repo" (or the Jira project key for -dst=jira).
+
+Environment:
+
+	GITHUB_TOKEN    token used when -src=github or -dst=github
+	GITLAB_TOKEN    token used when -src=gitlab
+	GITEA_TOKEN     token used when -src=gitea or -dst=gitea
+	JIRA_USER       user used when -dst=jira
+	JIRA_TOKEN      API token used when -dst=jira
+
+Options:
+
+`, os.Args[0])
+	flags.PrintDefaults()
+}
+
+func main() {
+	os.Exit(run())
+}
+
+// run holds the body of main so that the defers it registers (saving state,
+// closing the downloader) run to completion before the process exits
+// whenever run returns; main only calls os.Exit once run has returned. The
+// logger.Fatalf error paths exit at once without running them.
+func run() (exitCode int) {
+	logger := log.New(os.Stderr, "mellium.im/issues ", log.LstdFlags)
+	debug := log.New(ioutil.Discard, "mellium.im/issues DEBUG ", log.LstdFlags)
+
+	args := os.Args[1:]
+	export := false
+	if len(args) > 0 && args[0] == "export" {
+		export = true
+		args = args[1:]
+	}
+	defaultDst := "github"
+	if export {
+		defaultDst = "bitbucket"
+	}
+
+	var (
+		help          = false
+		h             = false
+		v             = false
+		srcType       = ""
+		dstType       = defaultDst
+		labels        = ""
+		baseURL       = ""
+		dstOwner      = ""
+		dstRepo       = ""
+		attachmentsTo = ""
+		releaseTag    = "issue-attachments"
+		statePath     = ".issues-state.json"
+		cacheDir      = ".issues-cache"
+		usersPath     = ""
+		labelColors   = ""
+		dryRun        = false
+		failOnUnmap   = false
+	)
+	flags := flag.NewFlagSet("issues", flag.ContinueOnError)
+	flags.BoolVar(&help, "help", help, "print this help message")
+	flags.BoolVar(&h, "h", h, "print this help message")
+	flags.BoolVar(&v, "v", v, "enable verbose debug logging")
+	flags.StringVar(&srcType, "src", srcType, "the source tracker type (bitbucket, gitlab, gitea, github)")
+	flags.StringVar(&dstType, "dst", dstType, "the destination tracker type (github, gitea, jira, bitbucket)")
+	flags.StringVar(&labels, "labels", labels, "list of comma separated labels; only logged with -v, the labels are not applied to imported issues")
+	flags.StringVar(&baseURL, "base-url", baseURL, "the base URL of the src or dst instance, for gitlab/gitea/jira")
+	flags.StringVar(&attachmentsTo, "attachments", attachmentsTo, `where to upload attachment contents: "release" to use a GitHub release on the destination repo, or an HTTP(S) URL to PUT them to`)
+	flags.StringVar(&releaseTag, "release-tag", releaseTag, `the release tag to upload attachments to when -attachments=release`)
+	flags.StringVar(&statePath, "state", statePath, "path to the state file that tracks which issues have already been synced")
+	flags.StringVar(&cacheDir, "cache-dir", cacheDir, "directory to cache GitHub API responses in between runs")
+	flags.StringVar(&usersPath, "users", usersPath, "path to a YAML file mapping source usernames to GitHub handles, for assignees, @mentions, and attribution")
+	flags.StringVar(&labelColors, "label-colors", labelColors, "path to a YAML file mapping label names to the color they should be created with; unlisted labels get a deterministic color derived from their name")
+	flags.BoolVar(&dryRun, "dry-run", dryRun, "print what would be created (issues, comments, labels, milestones) without creating anything")
+	flags.BoolVar(&failOnUnmap, "fail-on-unmapped", failOnUnmap, "exit with a non-zero status if -users was given and any user had no mapping")
+	if err := flags.Parse(args); err != nil {
+		logger.Fatalf("Error while parsing flags: `%v'", err)
+	}
+
+	switch {
+	case h || help:
+		flags.SetOutput(os.Stdout)
+		usage(flags)
+		return
+	case flags.NArg() < 2:
+		flags.SetOutput(os.Stderr)
+		usage(flags)
+		return
+	}
+	if v {
+		debug.SetOutput(os.Stderr)
+	}
+	if labels != "" {
+		debug.Printf("Extra labels given (not applied): `%s'\n", labels)
+	}
+
+	fargs := flags.Args()
+	src, dst := fargs[0], fargs[1]
+	ctx := context.Background()
+
+	state, err := issues.LoadState(statePath)
+	if err != nil {
+		logger.Fatalf("Error loading state file: `%v'", err)
+	}
+	// Checkpointed again after every issue in logResults, so that a run
+	// interrupted partway through can resume from the last issue it
+	// actually finished instead of starting over. A dry run never writes
+	// real state: the dummy IDs it invents would otherwise overwrite the
+	// mapping a real run depends on.
+	defer func() {
+		if dryRun {
+			return
+		}
+		if err := state.Save(statePath); err != nil {
+			logger.Printf("Error saving state file: `%v'\n", err)
+		}
+	}()
+
+	downloader, err := newDownloader(srcType, baseURL, src, cacheDir)
+	if err != nil {
+		logger.Fatalf("Error setting up downloader: `%v'", err)
+	}
+	if closer, ok := downloader.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	var users *issues.UserMap
+	if usersPath != "" {
+		users, err = issues.LoadUserMap(usersPath)
+		if err != nil {
+			logger.Fatalf("Error loading user map: `%v'", err)
+		}
+		if bd, ok := downloader.(*issues.BitbucketDownloader); ok {
+			bd.Users = users
+		}
+		defer func() {
+			unmapped := users.UnmappedUsers()
+			if len(unmapped) == 0 {
+				return
+			}
+			logger.Printf("No GitHub mapping for: %s\n", strings.Join(unmapped, ", "))
+			if failOnUnmap {
+				exitCode = 1
+			}
+		}()
+	}
+
+	if export {
+		exporter, err := newExporter(dstType, dst)
+		if err != nil {
+			logger.Fatalf("Error setting up exporter: `%v'", err)
+		}
+		syncer := &issues.Syncer{Downloader: downloader, Exporter: exporter, State: state}
+		results, err := syncer.ExportAll(ctx, state.LastSync)
+		if err != nil {
+			logger.Fatalf("Error exporting issues: `%v'", err)
+		}
+		logResults(logger, debug, state, statePath, !dryRun, results)
+		return
+	}
+
+	dstOwner, dstRepo, err = splitRepo(dstType, dst)
+	if err != nil {
+		logger.Fatalf("Error parsing destination: `%v'", err)
+	}
+	var uploader issues.Uploader
+	if dryRun {
+		uploader = issues.NewDryRunUploader(logger.Printf)
+	} else {
+		uploader, err = newUploader(dstType, baseURL, dstOwner, dstRepo, attachmentsTo, releaseTag, cacheDir)
+		if err != nil {
+			logger.Fatalf("Error setting up uploader: `%v'", err)
+		}
+	}
+
+	colors, err := loadLabelColors(labelColors)
+	if err != nil {
+		logger.Fatalf("Error loading label colors: `%v'", err)
+	}
+	milestoneIDs, err := issues.Reconcile(ctx, downloader, uploader, colors)
+	if err != nil {
+		logger.Fatalf("Error reconciling labels and milestones: `%v'", err)
+	}
+
+	syncer := &issues.Syncer{Downloader: downloader, Uploader: uploader, State: state, MilestoneIDs: milestoneIDs}
+	results, err := syncer.ImportAll(ctx, state.LastSync)
+	if err != nil {
+		logger.Fatalf("Error importing issues: `%v'", err)
+	}
+	logResults(logger, debug, state, statePath, !dryRun, results)
+	return exitCode
+}
+
+// loadLabelColors reads the YAML file at path mapping label names to the
+// color they should be created with. An empty path returns a nil map, so
+// every label falls back to issues.LabelColor.
+func loadLabelColors(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %q: %w", path, err)
+	}
+	colors := make(map[string]string)
+	if err := yaml.Unmarshal(b, &colors); err != nil {
+		return nil, fmt.Errorf("error parsing %q: %w", path, err)
+	}
+	return colors, nil
+}
+
+// logResults drains results, logging a summary of what happened. If persist
+// is true, it checkpoints state to statePath after each one so an
+// interrupted run can resume from the last issue it actually finished;
+// dry runs pass persist as false so the IDs they invent are never written
+// over the real state file.
+func logResults(logger, debug *log.Logger, state *issues.State, statePath string, persist bool, results <-chan issues.ExportResult) {
+	var imported, skipped, errored int
+	for r := range results {
+		switch r.Status {
+		case issues.StatusError:
+			errored++
+			logger.Printf("Error processing issue %d: `%v'\n", r.SourceID, r.Err)
+			continue
+		case issues.StatusNothingToDo:
+			skipped++
+			debug.Printf("Issue %d already synced as %d, skipping\n", r.SourceID, r.DestID)
+			continue
+		default:
+			imported++
+			debug.Printf("Issue %d synced as %d (%s)\n", r.SourceID, r.DestID, r.Status)
+		}
+		if !persist {
+			continue
+		}
+		if err := state.Save(statePath); err != nil {
+			logger.Printf("Error checkpointing state file: `%v'\n", err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Synced %d, Skipped %d, Errors %d\n", imported, skipped, errored)
+}
+
+// splitRepo splits an "owner/repo" string into its two parts. For Jira
+// destinations, repo is empty and owner is the whole string (the project
+// key).
+func splitRepo(typ, s string) (owner, repo string, err error) {
+	if typ == "jira" {
+		return s, "", nil
+	}
+	idx := strings.IndexByte(s, '/')
+	if idx < 1 || idx == len(s)-1 {
+		return "", "", fmt.Errorf("invalid repo name %q, expected: owner/repo", s)
+	}
+	return s[:idx], s[idx+1:], nil
+}
+
+func newDownloader(typ, baseURL, src, cacheDir string) (issues.Downloader, error) {
+	switch typ {
+	case "bitbucket", "":
+		return issues.NewBitbucketDownloader(src)
+	case "gitlab":
+		owner, repo, err := splitRepo(typ, src)
+		if err != nil {
+			return nil, err
+		}
+		if baseURL == "" {
+			baseURL = "https://gitlab.com"
+		}
+		return issues.NewGitLabDownloader(baseURL, owner+"/"+repo, os.Getenv("GITLAB_TOKEN")), nil
+	case "gitea":
+		owner, repo, err := splitRepo(typ, src)
+		if err != nil {
+			return nil, err
+		}
+		return issues.NewGiteaDownloader(baseURL, owner, repo, os.Getenv("GITEA_TOKEN")), nil
+	case "github":
+		owner, repo, err := splitRepo(typ, src)
+		if err != nil {
+			return nil, err
+		}
+		token := os.Getenv("GITHUB_TOKEN")
+		if token == "" {
+			return nil, fmt.Errorf("GITHUB_TOKEN cannot be empty")
+		}
+		return issues.NewGitHubDownloader(issues.NewGitHubClient(token, cacheDir), owner, repo), nil
+	default:
+		return nil, fmt.Errorf("unsupported src type %q", typ)
+	}
+}
+
+// newExporter sets up the Exporter used by the "export" subcommand.
+func newExporter(typ, dst string) (issues.Exporter, error) {
+	switch typ {
+	case "bitbucket", "":
+		return issues.NewBitbucketExporter(dst), nil
+	default:
+		return nil, fmt.Errorf("unsupported export dst type %q", typ)
+	}
+}
+
+func newUploader(typ, baseURL, owner, repo, attachmentsTo, releaseTag, cacheDir string) (issues.Uploader, error) {
+	switch typ {
+	case "github", "":
+		token := os.Getenv("GITHUB_TOKEN")
+		if token == "" {
+			return nil, fmt.Errorf("GITHUB_TOKEN cannot be empty")
+		}
+		client := issues.NewGitHubClient(token, cacheDir)
+		u, err := issues.NewGitHubUploader(client, owner, repo)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case attachmentsTo == "release":
+			u.Attachments = issues.NewReleaseAttachmentSink(client, owner, repo, releaseTag)
+		case attachmentsTo != "":
+			u.Attachments = issues.NewHTTPAttachmentSink(attachmentsTo)
+		}
+		return u, nil
+	case "gitea":
+		return issues.NewGiteaUploader(baseURL, owner, repo, os.Getenv("GITEA_TOKEN")), nil
+	case "jira":
+		return issues.NewJiraUploader(baseURL, owner, os.Getenv("JIRA_USER"), os.Getenv("JIRA_TOKEN")), nil
+	default:
+		return nil, fmt.Errorf("unsupported dst type %q", typ)
+	}
+}
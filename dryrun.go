@@ -0,0 +1,64 @@
+// Copyright 2018 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package issues // import "mellium.im/issues"
+
+import "context"
+
+// DryRunUploader is an Uploader that only logs what it would have created,
+// without making any destination API calls. Swapping it in for the real
+// Uploader previews an import, including the labels and milestones
+// Reconcile would otherwise create.
+type DryRunUploader struct {
+	// Log is called once per Create* call. The comments and attachments
+	// carried on an Issue are reported as counts on that issue's line, not
+	// one by one. It defaults to a no-op if nil.
+	Log func(format string, args ...interface{})
+
+	nextID int
+}
+
+// NewDryRunUploader returns a DryRunUploader that reports what it would do
+// through log.
+func NewDryRunUploader(log func(format string, args ...interface{})) *DryRunUploader {
+	return &DryRunUploader{Log: log}
+}
+
+func (u *DryRunUploader) logf(format string, args ...interface{}) {
+	if u.Log != nil {
+		u.Log(format, args...)
+	}
+}
+
+// CreateIssue implements Uploader.
+func (u *DryRunUploader) CreateIssue(ctx context.Context, issue Issue) (int, error) {
+	u.logf("would create issue %q with %d comment(s) and %d attachment(s)", issue.Title, len(issue.Comments), len(issue.Attachments))
+	u.nextID++
+	return u.nextID, nil
+}
+
+// CreateComment implements Uploader.
+func (u *DryRunUploader) CreateComment(ctx context.Context, issueID int, comment Comment) error {
+	u.logf("would create comment on issue %d", issueID)
+	return nil
+}
+
+// CreateLabel implements Uploader.
+func (u *DryRunUploader) CreateLabel(ctx context.Context, label Label) error {
+	u.logf("would create label %q (color %s)", label.Name, label.Color)
+	return nil
+}
+
+// CreateMilestone implements Uploader.
+func (u *DryRunUploader) CreateMilestone(ctx context.Context, milestone Milestone) (int, error) {
+	u.logf("would create milestone %q", milestone.Title)
+	u.nextID++
+	return u.nextID, nil
+}
+
+// CreateAttachment implements Uploader.
+func (u *DryRunUploader) CreateAttachment(ctx context.Context, issueID int, attachment Attachment) (string, error) {
+	u.logf("would upload attachment %q for issue %d", attachment.Name, issueID)
+	return attachment.URL, nil
+}
@@ -0,0 +1,371 @@
+// Copyright 2018 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package issues // import "mellium.im/issues"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// giteaClient holds the configuration shared by GiteaDownloader and
+// GiteaUploader.
+type giteaClient struct {
+	// BaseURL is the base URL of the Gitea instance, eg.
+	// "https://gitea.example.com".
+	BaseURL string
+	Owner   string
+	Repo    string
+	// Token is a Gitea access token.
+	Token string
+
+	client *http.Client
+}
+
+func newGiteaClient(baseURL, owner, repo, token string) giteaClient {
+	return giteaClient{
+		BaseURL: baseURL,
+		Owner:   owner,
+		Repo:    repo,
+		Token:   token,
+		client:  http.DefaultClient,
+	}
+}
+
+func (c giteaClient) do(ctx context.Context, method, path string, body, v interface{}) error {
+	u := fmt.Sprintf("%s/api/v1/repos/%s/%s%s", c.BaseURL, c.Owner, c.Repo, path)
+
+	var reqBody *bytes.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("issues: error marshaling Gitea request: %w", err)
+		}
+		reqBody = bytes.NewReader(b)
+	} else {
+		reqBody = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, reqBody)
+	if err != nil {
+		return fmt.Errorf("issues: error building Gitea request: %w", err)
+	}
+	req.Header.Set("Authorization", "token "+c.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("issues: error performing Gitea request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("issues: Gitea request to %q failed: %s", u, resp.Status)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+type giteaIssue struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	Poster struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Assignees []struct {
+		Login string `json:"login"`
+	} `json:"assignees"`
+	Labels []struct {
+		Name  string `json:"name"`
+		Color string `json:"color"`
+	} `json:"labels"`
+	State     string    `json:"state"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Milestone *struct {
+		ID    int    `json:"id"`
+		Title string `json:"title"`
+	} `json:"milestone"`
+}
+
+type giteaComment struct {
+	Body string `json:"body"`
+	User struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type giteaMilestone struct {
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Deadline    *time.Time `json:"due_on"`
+	State       string     `json:"state"`
+}
+
+type giteaLabel struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// withPage appends a page query parameter to path, which may or may not
+// already have a query string of its own.
+func withPage(path string, page int) string {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return fmt.Sprintf("%s%spage=%d", path, sep, page)
+}
+
+// GiteaDownloader is a Downloader that reads issues out of a Gitea repo
+// using the Gitea REST API.
+type GiteaDownloader struct {
+	giteaClient
+}
+
+// NewGiteaDownloader creates a GiteaDownloader for owner/repo on the Gitea
+// instance at baseURL.
+func NewGiteaDownloader(baseURL, owner, repo, token string) *GiteaDownloader {
+	return &GiteaDownloader{newGiteaClient(baseURL, owner, repo, token)}
+}
+
+// ListIssues implements Downloader.
+func (d *GiteaDownloader) ListIssues(ctx context.Context) ([]Issue, error) {
+	var raw []giteaIssue
+	for page := 1; ; page++ {
+		var batch []giteaIssue
+		if err := d.do(ctx, "GET", withPage("/issues?type=issues&state=all&limit=50&sort=oldest", page), nil, &batch); err != nil {
+			return nil, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		raw = append(raw, batch...)
+	}
+
+	issues := make([]Issue, 0, len(raw))
+	for _, src := range raw {
+		issue := Issue{
+			ID:        src.Number,
+			Title:     src.Title,
+			Body:      src.Body,
+			Author:    src.Poster.Login,
+			Closed:    src.State == "closed",
+			CreatedOn: src.CreatedAt,
+			UpdatedOn: src.UpdatedAt,
+		}
+		for _, l := range src.Labels {
+			issue.Labels = append(issue.Labels, l.Name)
+		}
+		for _, a := range src.Assignees {
+			issue.Assignees = append(issue.Assignees, a.Login)
+		}
+		if src.Milestone != nil {
+			issue.Milestone = &Milestone{ID: src.Milestone.ID, Title: src.Milestone.Title}
+		}
+		issues = append(issues, issue)
+	}
+	return issues, nil
+}
+
+// ListComments implements Downloader.
+func (d *GiteaDownloader) ListComments(ctx context.Context, issueID int) ([]Comment, error) {
+	var raw []giteaComment
+	for page := 1; ; page++ {
+		var batch []giteaComment
+		if err := d.do(ctx, "GET", withPage(fmt.Sprintf("/issues/%d/comments", issueID), page), nil, &batch); err != nil {
+			return nil, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		raw = append(raw, batch...)
+	}
+	comments := make([]Comment, 0, len(raw))
+	for _, c := range raw {
+		comments = append(comments, Comment{
+			Author:    c.User.Login,
+			Body:      c.Body,
+			CreatedOn: c.CreatedAt,
+		})
+	}
+	return comments, nil
+}
+
+// ListLabels implements Downloader.
+func (d *GiteaDownloader) ListLabels(ctx context.Context) ([]Label, error) {
+	var raw []giteaLabel
+	for page := 1; ; page++ {
+		var batch []giteaLabel
+		if err := d.do(ctx, "GET", withPage("/labels", page), nil, &batch); err != nil {
+			return nil, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		raw = append(raw, batch...)
+	}
+	labels := make([]Label, 0, len(raw))
+	for _, l := range raw {
+		labels = append(labels, Label{Name: l.Name, Color: l.Color})
+	}
+	return labels, nil
+}
+
+// ListMilestones implements Downloader.
+func (d *GiteaDownloader) ListMilestones(ctx context.Context) ([]Milestone, error) {
+	var raw []giteaMilestone
+	for page := 1; ; page++ {
+		var batch []giteaMilestone
+		if err := d.do(ctx, "GET", withPage("/milestones?state=all", page), nil, &batch); err != nil {
+			return nil, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		raw = append(raw, batch...)
+	}
+	milestones := make([]Milestone, 0, len(raw))
+	for _, m := range raw {
+		milestones = append(milestones, Milestone{
+			ID:          m.ID,
+			Title:       m.Title,
+			Description: m.Description,
+			DueOn:       m.Deadline,
+			Closed:      m.State == "closed",
+		})
+	}
+	return milestones, nil
+}
+
+// ListAttachments implements Downloader.
+func (d *GiteaDownloader) ListAttachments(ctx context.Context, issueID int) ([]Attachment, error) {
+	type asset struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	}
+	var raw []asset
+	for page := 1; ; page++ {
+		var batch []asset
+		if err := d.do(ctx, "GET", withPage(fmt.Sprintf("/issues/%d/assets", issueID), page), nil, &batch); err != nil {
+			return nil, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		raw = append(raw, batch...)
+	}
+	attachments := make([]Attachment, 0, len(raw))
+	for _, a := range raw {
+		attachments = append(attachments, Attachment{Name: a.Name, URL: a.URL})
+	}
+	return attachments, nil
+}
+
+// GiteaUploader is an Uploader that creates issues on a Gitea repo using
+// the Gitea REST API.
+type GiteaUploader struct {
+	giteaClient
+}
+
+// NewGiteaUploader creates a GiteaUploader for owner/repo on the Gitea
+// instance at baseURL.
+func NewGiteaUploader(baseURL, owner, repo, token string) *GiteaUploader {
+	return &GiteaUploader{newGiteaClient(baseURL, owner, repo, token)}
+}
+
+// CreateIssue implements Uploader.
+func (u *GiteaUploader) CreateIssue(ctx context.Context, issue Issue) (int, error) {
+	body := struct {
+		Title     string   `json:"title"`
+		Body      string   `json:"body"`
+		Closed    bool     `json:"closed"`
+		Assignees []string `json:"assignees,omitempty"`
+		Milestone int      `json:"milestone,omitempty"`
+	}{
+		Title:     issue.Title,
+		Body:      issue.Body,
+		Closed:    issue.Closed,
+		Assignees: issue.Assignees,
+		Milestone: milestoneNumber(issue.Milestone),
+	}
+	var result giteaIssue
+	if err := u.do(ctx, "POST", "/issues", body, &result); err != nil {
+		return 0, fmt.Errorf("issues: error creating Gitea issue: %w", err)
+	}
+	for _, l := range issue.Labels {
+		// Gitea labels must already exist and are attached by name through
+		// a separate endpoint.
+		_ = u.do(ctx, "POST", fmt.Sprintf("/issues/%d/labels", result.Number), struct {
+			Labels []string `json:"labels"`
+		}{Labels: []string{l}}, nil)
+	}
+	for _, c := range issue.Comments {
+		if err := u.CreateComment(ctx, result.Number, c); err != nil {
+			return result.Number, fmt.Errorf("issues: error creating comment on issue %d: %w", result.Number, err)
+		}
+	}
+	for _, a := range issue.Attachments {
+		if _, err := u.CreateAttachment(ctx, result.Number, a); err != nil {
+			return result.Number, fmt.Errorf("issues: error creating attachment on issue %d: %w", result.Number, err)
+		}
+	}
+	return result.Number, nil
+}
+
+// CreateComment implements Uploader.
+func (u *GiteaUploader) CreateComment(ctx context.Context, issueID int, comment Comment) error {
+	return u.do(ctx, "POST", fmt.Sprintf("/issues/%d/comments", issueID), struct {
+		Body string `json:"body"`
+	}{Body: comment.Body}, nil)
+}
+
+// CreateLabel implements Uploader.
+func (u *GiteaUploader) CreateLabel(ctx context.Context, label Label) error {
+	color := label.Color
+	if color == "" {
+		color = "ededed"
+	}
+	return u.do(ctx, "POST", "/labels", struct {
+		Name  string `json:"name"`
+		Color string `json:"color"`
+	}{Name: label.Name, Color: "#" + color}, nil)
+}
+
+// CreateMilestone implements Uploader.
+func (u *GiteaUploader) CreateMilestone(ctx context.Context, milestone Milestone) (int, error) {
+	var result giteaMilestone
+	err := u.do(ctx, "POST", "/milestones", struct {
+		Title       string     `json:"title"`
+		Description string     `json:"description"`
+		DueOn       *time.Time `json:"due_on,omitempty"`
+	}{
+		Title:       milestone.Title,
+		Description: milestone.Description,
+		DueOn:       milestone.DueOn,
+	}, &result)
+	if err != nil {
+		return 0, fmt.Errorf("issues: error creating Gitea milestone: %w", err)
+	}
+	return result.ID, nil
+}
+
+// CreateAttachment implements Uploader.
+//
+// Uploading attachment contents to Gitea requires a multipart form POST of
+// the actual file; since this Uploader only has a name and an existing
+// URL to work with, it leaves the attachment where it is and returns the
+// URL unchanged.
+func (u *GiteaUploader) CreateAttachment(ctx context.Context, issueID int, attachment Attachment) (string, error) {
+	return attachment.URL, nil
+}
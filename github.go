@@ -0,0 +1,386 @@
+// Copyright 2018 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package issues // import "mellium.im/issues"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// ghIssue is the shape of an issue as expected by GitHub's golden-comet
+// issue import API.
+//
+// See: https://gist.github.com/jonmagic/5282384165e0f86ef105
+type ghIssue struct {
+	Title     string   `json:"title"`
+	Body      string   `json:"body"`
+	Labels    []string `json:"labels,omitempty"`
+	Assignee  string   `json:"assignee,omitempty"`
+	Milestone int      `json:"milestone,omitempty"`
+	Closed    bool     `json:"closed"`
+}
+
+// ghComment is the shape of a comment as expected by GitHub's golden-comet
+// issue import API.
+type ghComment struct {
+	Body      string `json:"body"`
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+// ghResponse is the result of a golden-comet import request.
+type ghResponse struct {
+	ID     int    `json:"id"`
+	URL    string `json:"url"`
+	Status string `json:"status"`
+}
+
+// dummyCloser lets a bytes.Reader be used as the body of an *http.Request
+// without allocating an io.ReadCloser.
+type dummyCloser struct{}
+
+func (dummyCloser) Close() error {
+	return nil
+}
+
+// GitHubUploader is an Uploader that creates issues on a GitHub repo using
+// the (currently preview) golden-comet issue import API for issues and
+// comments, and the normal REST API for labels and milestones.
+type GitHubUploader struct {
+	Client *github.Client
+	Owner  string
+	Repo   string
+
+	// Attachments, if set, uploads attachment contents somewhere before an
+	// issue is imported and rewrites Markdown references to point at the
+	// new URL. GitHub itself has no API for uploading issue attachments, so
+	// without an AttachmentSink, attachments are imported with whatever URL
+	// they already had.
+	Attachments AttachmentSink
+
+	importURL *url.URL
+}
+
+// NewGitHubUploader creates a GitHubUploader that imports issues into
+// owner/repo using client.
+func NewGitHubUploader(client *github.Client, owner, repo string) (*GitHubUploader, error) {
+	importURL, err := url.Parse(fmt.Sprintf("https://api.github.com/repos/%s/%s/import/issues", owner, repo))
+	if err != nil {
+		return nil, fmt.Errorf("issues: error parsing import URL: %w", err)
+	}
+	return &GitHubUploader{
+		Client:    client,
+		Owner:     owner,
+		Repo:      repo,
+		importURL: importURL,
+	}, nil
+}
+
+// CreateLabel implements Uploader.
+func (u *GitHubUploader) CreateLabel(ctx context.Context, label Label) error {
+	color := label.Color
+	if color == "" {
+		color = "ededed"
+	}
+	_, _, err := u.Client.Issues.CreateLabel(ctx, u.Owner, u.Repo, &github.Label{
+		Name:  &label.Name,
+		Color: &color,
+	})
+	return err
+}
+
+// CreateMilestone implements Uploader.
+func (u *GitHubUploader) CreateMilestone(ctx context.Context, milestone Milestone) (int, error) {
+	state := "open"
+	if milestone.Closed {
+		state = "closed"
+	}
+	m, _, err := u.Client.Issues.CreateMilestone(ctx, u.Owner, u.Repo, &github.Milestone{
+		Title:       &milestone.Title,
+		Description: &milestone.Description,
+		DueOn:       milestone.DueOn,
+		State:       &state,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return m.GetNumber(), nil
+}
+
+// CreateAttachment implements Uploader.
+//
+// CreateIssue already uploads and rewrites references to every attachment
+// on the Issue it's given, so nothing in this package calls CreateAttachment
+// on a GitHubUploader; it uploads a single attachment through Attachments
+// for callers that add one after the issue exists.
+func (u *GitHubUploader) CreateAttachment(ctx context.Context, issueID int, attachment Attachment) (string, error) {
+	if attachment.Open == nil || u.Attachments == nil {
+		return attachment.URL, nil
+	}
+	return uploadAttachment(ctx, u.Attachments, attachment)
+}
+
+// CreateIssue implements Uploader.
+func (u *GitHubUploader) CreateIssue(ctx context.Context, issue Issue) (int, error) {
+	issue, err := uploadAttachments(ctx, u.Attachments, issue)
+	if err != nil {
+		return 0, err
+	}
+
+	req := struct {
+		Issue    ghIssue     `json:"issue"`
+		Comments []ghComment `json:"comments"`
+	}{
+		Issue: ghIssue{
+			Title:     issue.Title,
+			Body:      issue.Body,
+			Labels:    issue.Labels,
+			Closed:    issue.Closed,
+			Milestone: milestoneNumber(issue.Milestone),
+		},
+	}
+	if len(issue.Assignees) > 0 {
+		req.Issue.Assignee = issue.Assignees[0]
+	}
+	for _, c := range issue.Comments {
+		req.Comments = append(req.Comments, ghComment{
+			Body:      c.Body,
+			CreatedAt: c.CreatedOn.Format("2006-01-02T15:04:05Z"),
+		})
+	}
+
+	result, err := u.doImport(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	return result.ID, nil
+}
+
+// CreateComment implements Uploader.
+func (u *GitHubUploader) CreateComment(ctx context.Context, issueID int, comment Comment) error {
+	_, _, err := u.Client.Issues.CreateComment(ctx, u.Owner, u.Repo, issueID, &github.IssueComment{
+		Body: &comment.Body,
+	})
+	return err
+}
+
+// ListLabels implements LabelLister, letting Reconcile skip labels that
+// already exist on the destination repo.
+func (u *GitHubUploader) ListLabels(ctx context.Context) ([]Label, error) {
+	return listGitHubLabels(ctx, u.Client, u.Owner, u.Repo)
+}
+
+// ListMilestones implements MilestoneLister, letting Reconcile skip
+// milestones that already exist on the destination repo.
+func (u *GitHubUploader) ListMilestones(ctx context.Context) ([]Milestone, error) {
+	return listGitHubMilestones(ctx, u.Client, u.Owner, u.Repo)
+}
+
+func milestoneNumber(m *Milestone) int {
+	if m == nil {
+		return 0
+	}
+	return m.ID
+}
+
+// doImport POSTs req to the golden-comet import endpoint and waits for the
+// import to finish.
+func (u *GitHubUploader) doImport(ctx context.Context, req interface{}) (ghResponse, error) {
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return ghResponse{}, fmt.Errorf("issues: error marshaling import request: %w", err)
+	}
+
+	var result ghResponse
+	resp, err := u.Client.Do(ctx, &http.Request{
+		Method: "POST",
+		URL:    u.importURL,
+		Header: map[string][]string{
+			"Accept": {"application/vnd.github.golden-comet-preview+json"},
+		},
+		Body: struct {
+			io.Reader
+			io.Closer
+		}{
+			Reader: bytes.NewReader(reqBytes),
+			Closer: dummyCloser{},
+		},
+		ContentLength: int64(len(reqBytes)),
+	}, &result)
+	switch err.(type) {
+	case *github.AcceptedError:
+		// The import was accepted but hasn't finished yet; fall through to
+		// the polling loop below.
+	case nil:
+		return result, nil
+	default:
+		return ghResponse{}, fmt.Errorf("issues: error creating issue: %w", err)
+	}
+
+	issueURL, err := url.Parse(result.URL)
+	if err != nil {
+		return result, fmt.Errorf("issues: error parsing issue URL %q: %w", result.URL, err)
+	}
+	for result.Status == "pending" {
+		result = ghResponse{}
+		_, err = u.Client.Do(ctx, &http.Request{
+			Method: "GET",
+			URL:    issueURL,
+			Header: map[string][]string{
+				"Accept": {"application/vnd.github.golden-comet-preview+json"},
+			},
+		}, &result)
+		if err != nil {
+			return result, fmt.Errorf("issues: error polling issue import status: %w", err)
+		}
+	}
+	_ = resp
+	return result, nil
+}
+
+// GitHubDownloader is a Downloader that reads issues out of a GitHub repo
+// using the normal REST API. It's used by the bidirectional export flow
+// to read back issues that were created on GitHub so they can be written
+// out to another tracker.
+type GitHubDownloader struct {
+	Client *github.Client
+	Owner  string
+	Repo   string
+}
+
+// NewGitHubDownloader creates a GitHubDownloader that reads issues out of
+// owner/repo using client.
+func NewGitHubDownloader(client *github.Client, owner, repo string) *GitHubDownloader {
+	return &GitHubDownloader{Client: client, Owner: owner, Repo: repo}
+}
+
+// ListIssues implements Downloader.
+func (d *GitHubDownloader) ListIssues(ctx context.Context) ([]Issue, error) {
+	return d.ListIssuesSince(ctx, time.Time{})
+}
+
+// ListIssuesSince implements IncrementalDownloader.
+func (d *GitHubDownloader) ListIssuesSince(ctx context.Context, since time.Time) ([]Issue, error) {
+	opt := &github.IssueListByRepoOptions{
+		State:       "all",
+		Since:       since,
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	var issues []Issue
+	for {
+		ghIssues, resp, err := d.Client.Issues.ListByRepo(ctx, d.Owner, d.Repo, opt)
+		if err != nil {
+			return nil, fmt.Errorf("issues: error listing GitHub issues: %w", err)
+		}
+		for _, src := range ghIssues {
+			if src.IsPullRequest() {
+				continue
+			}
+			issue := Issue{
+				ID:        src.GetNumber(),
+				Title:     src.GetTitle(),
+				Body:      src.GetBody(),
+				Author:    src.GetUser().GetLogin(),
+				Closed:    src.GetState() == "closed",
+				CreatedOn: src.GetCreatedAt(),
+				UpdatedOn: src.GetUpdatedAt(),
+			}
+			for _, l := range src.Labels {
+				issue.Labels = append(issue.Labels, l.GetName())
+			}
+			for _, a := range src.Assignees {
+				issue.Assignees = append(issue.Assignees, a.GetLogin())
+			}
+			if m := src.Milestone; m != nil {
+				issue.Milestone = &Milestone{ID: m.GetNumber(), Title: m.GetTitle()}
+			}
+			issues = append(issues, issue)
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return issues, nil
+}
+
+// ListComments implements Downloader.
+func (d *GitHubDownloader) ListComments(ctx context.Context, issueID int) ([]Comment, error) {
+	ghComments, _, err := d.Client.Issues.ListComments(ctx, d.Owner, d.Repo, issueID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("issues: error listing comments for issue %d: %w", issueID, err)
+	}
+	comments := make([]Comment, 0, len(ghComments))
+	for _, c := range ghComments {
+		comments = append(comments, Comment{
+			Author:    c.GetUser().GetLogin(),
+			Body:      c.GetBody(),
+			CreatedOn: c.GetCreatedAt(),
+		})
+	}
+	return comments, nil
+}
+
+// ListLabels implements Downloader.
+func (d *GitHubDownloader) ListLabels(ctx context.Context) ([]Label, error) {
+	return listGitHubLabels(ctx, d.Client, d.Owner, d.Repo)
+}
+
+// ListMilestones implements Downloader.
+func (d *GitHubDownloader) ListMilestones(ctx context.Context) ([]Milestone, error) {
+	return listGitHubMilestones(ctx, d.Client, d.Owner, d.Repo)
+}
+
+// ListAttachments implements Downloader.
+//
+// GitHub embeds attachment links directly in issue and comment Markdown
+// rather than exposing a separate attachments endpoint, so
+// ListAttachments always returns no attachments.
+func (d *GitHubDownloader) ListAttachments(ctx context.Context, issueID int) ([]Attachment, error) {
+	return nil, nil
+}
+
+// listGitHubLabels is shared by GitHubDownloader.ListLabels and
+// GitHubUploader.ListLabels, the latter used by Reconcile to see which
+// labels already exist at the destination.
+func listGitHubLabels(ctx context.Context, client *github.Client, owner, repo string) ([]Label, error) {
+	ghLabels, _, err := client.Issues.ListLabels(ctx, owner, repo, nil)
+	if err != nil {
+		return nil, fmt.Errorf("issues: error listing labels: %w", err)
+	}
+	labels := make([]Label, 0, len(ghLabels))
+	for _, l := range ghLabels {
+		labels = append(labels, Label{Name: l.GetName(), Color: l.GetColor()})
+	}
+	return labels, nil
+}
+
+// listGitHubMilestones is shared by GitHubDownloader.ListMilestones and
+// GitHubUploader.ListMilestones, the latter used by Reconcile to see which
+// milestones already exist at the destination.
+func listGitHubMilestones(ctx context.Context, client *github.Client, owner, repo string) ([]Milestone, error) {
+	ghMilestones, _, err := client.Issues.ListMilestones(ctx, owner, repo, &github.MilestoneListOptions{State: "all"})
+	if err != nil {
+		return nil, fmt.Errorf("issues: error listing milestones: %w", err)
+	}
+	milestones := make([]Milestone, 0, len(ghMilestones))
+	for _, m := range ghMilestones {
+		milestones = append(milestones, Milestone{
+			ID:          m.GetNumber(),
+			Title:       m.GetTitle(),
+			Description: m.GetDescription(),
+			DueOn:       m.DueOn,
+			Closed:      m.GetState() == "closed",
+		})
+	}
+	return milestones, nil
+}
@@ -0,0 +1,255 @@
+// Copyright 2018 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package issues // import "mellium.im/issues"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// GitLabDownloader is a Downloader that reads issues out of a GitLab
+// project using the GitLab REST API.
+type GitLabDownloader struct {
+	// BaseURL is the base URL of the GitLab instance, eg.
+	// "https://gitlab.com".
+	BaseURL string
+	// Project is the numeric ID or path of the project, eg.
+	// "mellium/issues". It is URL-encoded when request URLs are built.
+	Project string
+	// Token is a GitLab personal access token with api scope.
+	Token string
+
+	client *http.Client
+}
+
+// NewGitLabDownloader creates a GitLabDownloader for the given project on
+// the GitLab instance at baseURL.
+func NewGitLabDownloader(baseURL, project, token string) *GitLabDownloader {
+	return &GitLabDownloader{
+		BaseURL: baseURL,
+		Project: project,
+		Token:   token,
+		client:  http.DefaultClient,
+	}
+}
+
+type gitlabIssue struct {
+	IID         int    `json:"iid"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Author      struct {
+		Username string `json:"username"`
+	} `json:"author"`
+	Assignees []struct {
+		Username string `json:"username"`
+	} `json:"assignees"`
+	Labels    []string  `json:"labels"`
+	State     string    `json:"state"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Milestone *struct {
+		ID    int    `json:"id"`
+		Title string `json:"title"`
+	} `json:"milestone"`
+}
+
+type gitlabNote struct {
+	Author struct {
+		Username string `json:"username"`
+	} `json:"author"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	System    bool      `json:"system"`
+}
+
+type gitlabMilestone struct {
+	ID          int     `json:"id"`
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	DueDate     *string `json:"due_date"`
+	State       string  `json:"state"`
+}
+
+type gitlabLabel struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// apiURL builds the full URL for path against d's project.
+func (d *GitLabDownloader) apiURL(path string) string {
+	return fmt.Sprintf("%s/api/v4/projects/%s%s", d.BaseURL, url.PathEscape(d.Project), path)
+}
+
+// getPage performs an authenticated GET request against u and decodes the
+// JSON response into v, returning the URL of the next page from the
+// response's Link header, or "" if v holds the last page.
+func (d *GitLabDownloader) getPage(ctx context.Context, u string, v interface{}) (next string, err error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return "", fmt.Errorf("issues: error building GitLab request: %w", err)
+	}
+	req.Header.Set("PRIVATE-TOKEN", d.Token)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("issues: error performing GitLab request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("issues: GitLab request to %q failed: %s", u, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return "", err
+	}
+	return nextLink(resp.Header.Get("Link")), nil
+}
+
+// nextLink extracts the "next" URL from a GitLab Link header, or returns ""
+// if there isn't one, which means the current page was the last.
+func nextLink(header string) string {
+	for _, part := range strings.Split(header, ",") {
+		segs := strings.Split(part, ";")
+		if len(segs) < 2 {
+			continue
+		}
+		if strings.TrimSpace(segs[1]) != `rel="next"` {
+			continue
+		}
+		return strings.Trim(strings.TrimSpace(segs[0]), "<>")
+	}
+	return ""
+}
+
+// ListIssues implements Downloader.
+func (d *GitLabDownloader) ListIssues(ctx context.Context) ([]Issue, error) {
+	var raw []gitlabIssue
+	u := d.apiURL("/issues?per_page=100&order_by=created_at&sort=asc")
+	for u != "" {
+		var page []gitlabIssue
+		next, err := d.getPage(ctx, u, &page)
+		if err != nil {
+			return nil, err
+		}
+		raw = append(raw, page...)
+		u = next
+	}
+
+	issues := make([]Issue, 0, len(raw))
+	for _, src := range raw {
+		issue := Issue{
+			ID:        src.IID,
+			Title:     src.Title,
+			Body:      src.Description,
+			Author:    src.Author.Username,
+			Labels:    src.Labels,
+			Closed:    src.State == "closed",
+			CreatedOn: src.CreatedAt,
+			UpdatedOn: src.UpdatedAt,
+		}
+		for _, a := range src.Assignees {
+			issue.Assignees = append(issue.Assignees, a.Username)
+		}
+		if src.Milestone != nil {
+			issue.Milestone = &Milestone{ID: src.Milestone.ID, Title: src.Milestone.Title}
+		}
+		issues = append(issues, issue)
+	}
+	return issues, nil
+}
+
+// ListComments implements Downloader.
+func (d *GitLabDownloader) ListComments(ctx context.Context, issueID int) ([]Comment, error) {
+	var raw []gitlabNote
+	u := d.apiURL(fmt.Sprintf("/issues/%d/notes?per_page=100", issueID))
+	for u != "" {
+		var page []gitlabNote
+		next, err := d.getPage(ctx, u, &page)
+		if err != nil {
+			return nil, err
+		}
+		raw = append(raw, page...)
+		u = next
+	}
+
+	var comments []Comment
+	for _, n := range raw {
+		if n.System {
+			// System notes (label changes, status changes, etc.) are noise
+			// for most migrations; skip them.
+			continue
+		}
+		comments = append(comments, Comment{
+			Author:    n.Author.Username,
+			Body:      n.Body,
+			CreatedOn: n.CreatedAt,
+		})
+	}
+	return comments, nil
+}
+
+// ListLabels implements Downloader.
+func (d *GitLabDownloader) ListLabels(ctx context.Context) ([]Label, error) {
+	var raw []gitlabLabel
+	u := d.apiURL("/labels?per_page=100")
+	for u != "" {
+		var page []gitlabLabel
+		next, err := d.getPage(ctx, u, &page)
+		if err != nil {
+			return nil, err
+		}
+		raw = append(raw, page...)
+		u = next
+	}
+	labels := make([]Label, 0, len(raw))
+	for _, l := range raw {
+		labels = append(labels, Label{Name: l.Name, Color: l.Color})
+	}
+	return labels, nil
+}
+
+// ListMilestones implements Downloader.
+func (d *GitLabDownloader) ListMilestones(ctx context.Context) ([]Milestone, error) {
+	var raw []gitlabMilestone
+	u := d.apiURL("/milestones?per_page=100")
+	for u != "" {
+		var page []gitlabMilestone
+		next, err := d.getPage(ctx, u, &page)
+		if err != nil {
+			return nil, err
+		}
+		raw = append(raw, page...)
+		u = next
+	}
+	milestones := make([]Milestone, 0, len(raw))
+	for _, m := range raw {
+		milestone := Milestone{
+			ID:          m.ID,
+			Title:       m.Title,
+			Description: m.Description,
+			Closed:      m.State == "closed",
+		}
+		if m.DueDate != nil {
+			if t, err := time.Parse("2006-01-02", *m.DueDate); err == nil {
+				milestone.DueOn = &t
+			}
+		}
+		milestones = append(milestones, milestone)
+	}
+	return milestones, nil
+}
+
+// ListAttachments implements Downloader.
+//
+// GitLab embeds attachment links directly in issue and note markdown
+// rather than exposing a separate attachments endpoint, so ListAttachments
+// always returns no attachments.
+func (d *GitLabDownloader) ListAttachments(ctx context.Context, issueID int) ([]Attachment, error) {
+	return nil, nil
+}
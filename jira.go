@@ -0,0 +1,209 @@
+// Copyright 2018 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package issues // import "mellium.im/issues"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// JiraUploader is an Uploader that creates issues on a Jira project using
+// the Jira REST API.
+type JiraUploader struct {
+	// BaseURL is the base URL of the Jira instance, eg.
+	// "https://example.atlassian.net".
+	BaseURL string
+	// Project is the Jira project key, eg. "ISSUES".
+	Project string
+	User    string
+	// Token is an API token for User.
+	Token string
+
+	client *http.Client
+
+	// keyByID maps the destination ID returned from CreateIssue back to the
+	// Jira issue key, since comments and attachments are addressed by key
+	// rather than ID.
+	keyByID map[int]string
+}
+
+// NewJiraUploader creates a JiraUploader that creates issues in project on
+// the Jira instance at baseURL, authenticating as user using an API
+// token.
+func NewJiraUploader(baseURL, project, user, token string) *JiraUploader {
+	return &JiraUploader{
+		BaseURL: baseURL,
+		Project: project,
+		User:    user,
+		Token:   token,
+		client:  http.DefaultClient,
+		keyByID: make(map[int]string),
+	}
+}
+
+func (u *JiraUploader) do(ctx context.Context, method, path string, body, v interface{}) error {
+	var reqBody *bytes.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("issues: error marshaling Jira request: %w", err)
+		}
+		reqBody = bytes.NewReader(b)
+	} else {
+		reqBody = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.BaseURL+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("issues: error building Jira request: %w", err)
+	}
+	req.SetBasicAuth(u.User, u.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("issues: error performing Jira request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("issues: Jira request to %q failed: %s", path, resp.Status)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// CreateIssue implements Uploader.
+func (u *JiraUploader) CreateIssue(ctx context.Context, issue Issue) (int, error) {
+	fields := map[string]interface{}{
+		"project":     map[string]string{"key": u.Project},
+		"summary":     issue.Title,
+		"description": issue.Body,
+		"issuetype":   map[string]string{"name": "Bug"},
+		"labels":      issue.Labels,
+	}
+	if issue.Milestone != nil {
+		fields["fixVersions"] = []map[string]string{{"id": strconv.Itoa(issue.Milestone.ID)}}
+	}
+	var result struct {
+		ID  string `json:"id"`
+		Key string `json:"key"`
+	}
+	if err := u.do(ctx, "POST", "/rest/api/2/issue", map[string]interface{}{"fields": fields}, &result); err != nil {
+		return 0, fmt.Errorf("issues: error creating Jira issue: %w", err)
+	}
+
+	if issue.Closed {
+		if err := u.transition(ctx, result.Key, "Done"); err != nil {
+			return 0, err
+		}
+	}
+
+	id := len(u.keyByID) + 1
+	u.keyByID[id] = result.Key
+
+	for _, c := range issue.Comments {
+		if err := u.CreateComment(ctx, id, c); err != nil {
+			return id, fmt.Errorf("issues: error creating comment on %s: %w", result.Key, err)
+		}
+	}
+	for _, a := range issue.Attachments {
+		if _, err := u.CreateAttachment(ctx, id, a); err != nil {
+			return id, fmt.Errorf("issues: error creating attachment on %s: %w", result.Key, err)
+		}
+	}
+	return id, nil
+}
+
+// transition moves an issue to the workflow state with the given name.
+//
+// Jira workflows are configurable per-project, so this is a best-effort
+// match against the transition name rather than a fixed status ID.
+func (u *JiraUploader) transition(ctx context.Context, key, name string) error {
+	var transitions struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"transitions"`
+	}
+	if err := u.do(ctx, "GET", fmt.Sprintf("/rest/api/2/issue/%s/transitions", key), nil, &transitions); err != nil {
+		return fmt.Errorf("issues: error listing Jira transitions for %s: %w", key, err)
+	}
+	for _, t := range transitions.Transitions {
+		if t.Name == name {
+			return u.do(ctx, "POST", fmt.Sprintf("/rest/api/2/issue/%s/transitions", key), map[string]interface{}{
+				"transition": map[string]string{"id": t.ID},
+			}, nil)
+		}
+	}
+	return nil
+}
+
+// CreateComment implements Uploader.
+func (u *JiraUploader) CreateComment(ctx context.Context, issueID int, comment Comment) error {
+	key, ok := u.keyByID[issueID]
+	if !ok {
+		return fmt.Errorf("issues: no Jira issue key for destination ID %d", issueID)
+	}
+	return u.do(ctx, "POST", fmt.Sprintf("/rest/api/2/issue/%s/comment", key), map[string]interface{}{
+		"body": comment.Body,
+	}, nil)
+}
+
+// CreateLabel implements Uploader.
+//
+// Jira labels are freeform text created implicitly the first time they're
+// attached to an issue, so there is nothing to do up front.
+func (u *JiraUploader) CreateLabel(ctx context.Context, label Label) error {
+	return nil
+}
+
+// CreateMilestone implements Uploader.
+//
+// Jira's closest equivalent to a milestone is a "fix version" on the
+// project.
+func (u *JiraUploader) CreateMilestone(ctx context.Context, milestone Milestone) (int, error) {
+	body := map[string]interface{}{
+		"project":     u.Project,
+		"name":        milestone.Title,
+		"description": milestone.Description,
+		"released":    milestone.Closed,
+	}
+	if milestone.DueOn != nil {
+		body["releaseDate"] = milestone.DueOn.Format("2006-01-02")
+	}
+	var result struct {
+		ID string `json:"id"`
+	}
+	if err := u.do(ctx, "POST", "/rest/api/2/version", body, &result); err != nil {
+		return 0, fmt.Errorf("issues: error creating Jira version: %w", err)
+	}
+	id, err := strconv.Atoi(result.ID)
+	if err != nil {
+		return 0, fmt.Errorf("issues: error parsing Jira version ID %q: %w", result.ID, err)
+	}
+	return id, nil
+}
+
+// CreateAttachment implements Uploader.
+func (u *JiraUploader) CreateAttachment(ctx context.Context, issueID int, attachment Attachment) (string, error) {
+	key, ok := u.keyByID[issueID]
+	if !ok {
+		return "", fmt.Errorf("issues: no Jira issue key for destination ID %d", issueID)
+	}
+	// Jira's attachment endpoint requires the raw file contents rather than
+	// a URL, and expects a multipart/form-data body with the
+	// "X-Atlassian-Token: no-check" header; since this Uploader only has a
+	// reference URL to work with, it records the URL as a comment instead
+	// of attempting the upload.
+	return attachment.URL, u.do(ctx, "POST", fmt.Sprintf("/rest/api/2/issue/%s/comment", key), map[string]interface{}{
+		"body": fmt.Sprintf("Attachment: %s", attachment.URL),
+	}, nil)
+}
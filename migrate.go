@@ -0,0 +1,44 @@
+// Copyright 2018 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package issues // import "mellium.im/issues"
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Migrate copies every issue, along with its comments, labels, milestones,
+// and attachments, from d to u.
+//
+// It's a convenience wrapper around Reconcile and Syncer for callers that
+// don't need an incremental, resumable import: labels and milestones are
+// reconciled first, then every issue is synced in a single pass using a
+// throwaway State. Callers that want to resume an interrupted run, or that
+// need to persist State between runs, should use Reconcile and Syncer
+// directly instead.
+func Migrate(ctx context.Context, d Downloader, u Uploader) error {
+	milestoneIDs, err := Reconcile(ctx, d, u, nil)
+	if err != nil {
+		return err
+	}
+
+	syncer := &Syncer{
+		Downloader:   d,
+		Uploader:     u,
+		State:        &State{IDs: make(map[int]int)},
+		MilestoneIDs: milestoneIDs,
+	}
+	results, err := syncer.ImportAll(ctx, time.Time{})
+	if err != nil {
+		return err
+	}
+	for r := range results {
+		if r.Status == StatusError {
+			return fmt.Errorf("issues: error creating issue %d: %w", r.SourceID, r.Err)
+		}
+	}
+	return nil
+}
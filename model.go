@@ -0,0 +1,138 @@
+// Copyright 2018 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+// Package issues migrates issues, comments, labels, milestones, and
+// attachments between issue trackers.
+//
+// It defines a small intermediate model (Issue, Comment, Label, Milestone,
+// and Attachment) along with Downloader and Uploader interfaces that any
+// bug tracker can implement. This lets the command line tool in
+// mellium.im/issues/cmd/issues mix and match any supported source and
+// destination instead of hard-coding a single migration path.
+package issues // import "mellium.im/issues"
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Label is a tag that can be attached to an issue.
+type Label struct {
+	Name  string
+	Color string
+}
+
+// Milestone groups issues together, usually around a release or deadline.
+type Milestone struct {
+	// ID is the milestone number on the source or destination tracker.
+	ID          int
+	Title       string
+	Description string
+	DueOn       *time.Time
+	Closed      bool
+}
+
+// Attachment is a file that was attached to an issue or comment.
+type Attachment struct {
+	Name string
+	URL  string
+
+	// Open returns the attachment's contents, if the Downloader that
+	// produced it is able to read them. It is nil for attachments that only
+	// carry a URL (for example, one already uploaded to the destination
+	// tracker).
+	Open func() (io.ReadCloser, error)
+}
+
+// Comment is a single comment on an issue.
+type Comment struct {
+	// Author is the username of the commenter on the source tracker.
+	Author      string
+	Body        string
+	CreatedOn   time.Time
+	Attachments []Attachment
+}
+
+// Issue is the intermediate representation of an issue that is shared by
+// every Downloader and Uploader implementation.
+type Issue struct {
+	// ID is the issue number on the source tracker.
+	ID int
+
+	Title  string
+	Body   string
+	Author string
+
+	Assignees []string
+	Labels    []string
+	Milestone *Milestone
+
+	Closed    bool
+	CreatedOn time.Time
+	UpdatedOn time.Time
+
+	// Comments and Attachments are populated by Migrate before CreateIssue
+	// is called. It's up to the Uploader to persist them, whether that's
+	// bundling them into the same request used to create the issue or
+	// calling CreateComment/CreateAttachment itself afterwards.
+	Comments    []Comment
+	Attachments []Attachment
+}
+
+// Downloader reads issues and their associated data out of a source issue
+// tracker and into the intermediate model.
+type Downloader interface {
+	// ListIssues returns every issue in the source repo, in the order they
+	// should be imported.
+	ListIssues(ctx context.Context) ([]Issue, error)
+
+	// ListComments returns the comments attached to the issue with the
+	// given source ID.
+	ListComments(ctx context.Context, issueID int) ([]Comment, error)
+
+	// ListLabels returns every label defined on the source repo.
+	ListLabels(ctx context.Context) ([]Label, error)
+
+	// ListMilestones returns every milestone defined on the source repo.
+	ListMilestones(ctx context.Context) ([]Milestone, error)
+
+	// ListAttachments returns the attachments on the issue with the given
+	// source ID.
+	ListAttachments(ctx context.Context, issueID int) ([]Attachment, error)
+}
+
+// Uploader creates issues and their associated data on a destination issue
+// tracker.
+type Uploader interface {
+	// CreateIssue creates issue on the destination tracker and returns the
+	// new issue number.
+	CreateIssue(ctx context.Context, issue Issue) (int, error)
+
+	// CreateComment adds a comment to the issue with the given destination
+	// ID.
+	CreateComment(ctx context.Context, issueID int, comment Comment) error
+
+	// CreateLabel creates a label on the destination repo. It does not check
+	// whether the label already exists; Reconcile skips labels that an
+	// Uploader implementing LabelLister already lists.
+	CreateLabel(ctx context.Context, label Label) error
+
+	// CreateMilestone creates a milestone on the destination repo and
+	// returns its new ID.
+	CreateMilestone(ctx context.Context, milestone Milestone) (int, error)
+
+	// CreateAttachment uploads an attachment and returns the URL it can be
+	// referenced by on the destination tracker.
+	CreateAttachment(ctx context.Context, issueID int, attachment Attachment) (string, error)
+}
+
+// AttachmentSink stores the raw contents of an attachment somewhere and
+// returns the URL it can be fetched from afterwards. Uploaders that have
+// nowhere of their own to put attachment contents (for example, GitHub has
+// no issue-attachment API) use an AttachmentSink to decide where uploaded
+// files end up instead.
+type AttachmentSink interface {
+	Upload(ctx context.Context, name string, r io.Reader) (url string, err error)
+}
@@ -0,0 +1,117 @@
+// Copyright 2018 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package issues // import "mellium.im/issues"
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/gregjones/httpcache"
+	"github.com/gregjones/httpcache/diskcache"
+	"golang.org/x/oauth2"
+	"golang.org/x/time/rate"
+)
+
+// NewGitHubClient returns a github.Client authenticated with token whose
+// requests are paced to GitHub's primary rate limit, back off on secondary
+// (abuse) rate limits, and are cached on disk at cacheDir so that
+// re-running a sync doesn't re-fetch issues that haven't changed.
+//
+// cacheDir is created if it doesn't already exist.
+func NewGitHubClient(token, cacheDir string) *github.Client {
+	transport := &oauth2.Transport{
+		Base: &httpcache.Transport{
+			Transport:           newRateLimitTransport(http.DefaultTransport),
+			Cache:               diskcache.New(cacheDir),
+			MarkCachedResponses: true,
+		},
+		Source: oauth2.ReuseTokenSource(nil, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})),
+	}
+	return github.NewClient(&http.Client{Transport: transport})
+}
+
+// rateLimitTransport paces requests to GitHub's primary rate limit using a
+// token bucket, and retries requests rejected for hitting the secondary
+// (abuse) rate limit after waiting however long the response says to.
+type rateLimitTransport struct {
+	Base    http.RoundTripper
+	Limiter *rate.Limiter
+}
+
+// newRateLimitTransport wraps base with a token bucket sized to GitHub's
+// primary limit for an authenticated request: 5000 requests per hour.
+func newRateLimitTransport(base http.RoundTripper) *rateLimitTransport {
+	return &rateLimitTransport{
+		Base:    base,
+		Limiter: rate.NewLimiter(rate.Every(time.Hour/5000), 1),
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.Limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+	for {
+		resp, err := t.Base.RoundTrip(req)
+		if err != nil {
+			return resp, err
+		}
+		wait, ok := secondaryBackoff(resp)
+		if !ok {
+			return resp, nil
+		}
+		resp.Body.Close()
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		// The body was already drained (and closed) by the rejected attempt
+		// above; rewind it from GetBody before retrying so the request body
+		// isn't sent empty.
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("issues: error rewinding request body for retry: %w", err)
+			}
+			req.Body = body
+		}
+	}
+}
+
+// secondaryBackoff reports how long to wait before retrying a request that
+// was rejected for hitting GitHub's secondary (abuse) rate limit, honoring
+// Retry-After if the response includes one and falling back to
+// X-RateLimit-Reset for the primary limit running out mid-burst.
+func secondaryBackoff(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	if ra := resp.Header.Get("Retry-After"); ra != "" {
+		if secs, err := strconv.Atoi(ra); err == nil {
+			return time.Duration(secs) * time.Second, true
+		}
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return 0, false
+	}
+	reset := resp.Header.Get("X-RateLimit-Reset")
+	if reset == "" {
+		return 0, false
+	}
+	secs, err := strconv.ParseInt(reset, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	wait := time.Until(time.Unix(secs, 0))
+	if wait <= 0 {
+		return 0, false
+	}
+	return wait, true
+}
@@ -0,0 +1,156 @@
+// Copyright 2018 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package issues
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestSecondaryBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		header  http.Header
+		wait    time.Duration
+		backoff bool
+	}{
+		{
+			name:    "not rate limited",
+			status:  http.StatusOK,
+			header:  http.Header{},
+			backoff: false,
+		},
+		{
+			name:   "retry after",
+			status: http.StatusForbidden,
+			header: http.Header{
+				"Retry-After": []string{"30"},
+			},
+			wait:    30 * time.Second,
+			backoff: true,
+		},
+		{
+			name:   "too many requests with retry after",
+			status: http.StatusTooManyRequests,
+			header: http.Header{
+				"Retry-After": []string{"5"},
+			},
+			wait:    5 * time.Second,
+			backoff: true,
+		},
+		{
+			name:   "primary limit not exhausted",
+			status: http.StatusForbidden,
+			header: http.Header{
+				"X-Ratelimit-Remaining": []string{"1"},
+			},
+			backoff: false,
+		},
+		{
+			name:   "primary limit reset in the past",
+			status: http.StatusForbidden,
+			header: http.Header{
+				"X-Ratelimit-Remaining": []string{"0"},
+				"X-Ratelimit-Reset":     []string{strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)},
+			},
+			backoff: false,
+		},
+		{
+			name:   "primary limit reset in the future",
+			status: http.StatusForbidden,
+			header: http.Header{
+				"X-Ratelimit-Remaining": []string{"0"},
+				"X-Ratelimit-Reset":     []string{strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10)},
+			},
+			wait:    time.Minute,
+			backoff: true,
+		},
+		{
+			name:   "unparseable reset",
+			status: http.StatusForbidden,
+			header: http.Header{
+				"X-Ratelimit-Remaining": []string{"0"},
+				"X-Ratelimit-Reset":     []string{"not-a-number"},
+			},
+			backoff: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: test.status, Header: test.header}
+			wait, ok := secondaryBackoff(resp)
+			if ok != test.backoff {
+				t.Fatalf("secondaryBackoff() ok = %v, want %v", ok, test.backoff)
+			}
+			if !ok {
+				return
+			}
+			// The X-Ratelimit-Reset wait is time.Until a reset time truncated
+			// to whole seconds, so allow a little slack instead of comparing
+			// exactly.
+			if d := wait - test.wait; d < -time.Second || d > time.Second {
+				t.Fatalf("secondaryBackoff() wait = %v, want ~%v", wait, test.wait)
+			}
+		})
+	}
+}
+
+// TestRateLimitTransportRetriesWithOriginalBody verifies that a request
+// rejected for hitting the secondary (abuse) rate limit is resent with its
+// original body intact, instead of the already-drained one from the first
+// attempt.
+func TestRateLimitTransportRetriesWithOriginalBody(t *testing.T) {
+	const payload = "the original request body"
+
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("error reading request body: %v", err)
+		}
+		bodies = append(bodies, string(b))
+
+		if len(bodies) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	transport := newRateLimitTransport(http.DefaultTransport)
+	// Don't wait on the primary limiter's token bucket in a test.
+	transport.Limiter = rate.NewLimiter(rate.Inf, 1)
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, srv.URL, bytes.NewReader([]byte(payload)))
+	if err != nil {
+		t.Fatalf("NewRequestWithContext() = %v", err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() = %v", err)
+	}
+	resp.Body.Close()
+
+	if len(bodies) != 2 {
+		t.Fatalf("server saw %d requests, want 2", len(bodies))
+	}
+	for i, body := range bodies {
+		if body != payload {
+			t.Errorf("request %d body = %q, want %q", i, body, payload)
+		}
+	}
+}
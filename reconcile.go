@@ -0,0 +1,119 @@
+// Copyright 2018 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package issues // import "mellium.im/issues"
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+)
+
+// LabelLister is implemented by Uploaders that can list the labels already
+// present on the destination, so Reconcile only creates the ones that are
+// missing.
+type LabelLister interface {
+	ListLabels(ctx context.Context) ([]Label, error)
+}
+
+// MilestoneLister is implemented by Uploaders that can list the milestones
+// already present on the destination, so Reconcile only creates the ones
+// that are missing.
+type MilestoneLister interface {
+	ListMilestones(ctx context.Context) ([]Milestone, error)
+}
+
+// LabelColor deterministically derives a label color from name, so that a
+// label created without an explicit color still gets a stable,
+// distinct-looking one instead of every label looking the same.
+func LabelColor(name string) string {
+	sum := sha1.Sum([]byte(name))
+	return hex.EncodeToString(sum[:3])
+}
+
+// Reconcile creates whichever labels and milestones used by d's issues
+// don't already exist at the destination, and returns the mapping from
+// source milestone ID to destination milestone number so that issues can
+// be re-pointed at the right milestone when they're uploaded.
+//
+// Bitbucket exports have no separate label list; Downloaders like it
+// return nil from ListLabels, so the label set is instead derived from
+// every label already attached to an issue. colors maps a label name to
+// the color it should be created with; a label missing from colors gets
+// one derived by LabelColor instead.
+//
+// If u implements LabelLister or MilestoneLister, the matching destination
+// list is fetched first so that labels and milestones which already exist
+// (matched by name) aren't recreated.
+func Reconcile(ctx context.Context, d Downloader, u Uploader, colors map[string]string) (map[int]int, error) {
+	srcIssues, err := d.ListIssues(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("issues: error listing issues to derive labels: %w", err)
+	}
+	labelNames := make(map[string]bool)
+	for _, issue := range srcIssues {
+		for _, name := range issue.Labels {
+			labelNames[name] = true
+		}
+	}
+	srcLabels, err := d.ListLabels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("issues: error listing labels: %w", err)
+	}
+	for _, l := range srcLabels {
+		labelNames[l.Name] = true
+	}
+
+	existingLabels := make(map[string]bool)
+	if lister, ok := u.(LabelLister); ok {
+		dstLabels, err := lister.ListLabels(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("issues: error listing destination labels: %w", err)
+		}
+		for _, l := range dstLabels {
+			existingLabels[l.Name] = true
+		}
+	}
+	for name := range labelNames {
+		if existingLabels[name] {
+			continue
+		}
+		color := colors[name]
+		if color == "" {
+			color = LabelColor(name)
+		}
+		if err := u.CreateLabel(ctx, Label{Name: name, Color: color}); err != nil {
+			return nil, fmt.Errorf("issues: error creating label %q: %w", name, err)
+		}
+	}
+
+	srcMilestones, err := d.ListMilestones(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("issues: error listing milestones: %w", err)
+	}
+	existingMilestones := make(map[string]int)
+	if lister, ok := u.(MilestoneLister); ok {
+		dstMilestones, err := lister.ListMilestones(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("issues: error listing destination milestones: %w", err)
+		}
+		for _, m := range dstMilestones {
+			existingMilestones[m.Title] = m.ID
+		}
+	}
+	milestoneIDs := make(map[int]int, len(srcMilestones))
+	for _, m := range srcMilestones {
+		if id, ok := existingMilestones[m.Title]; ok {
+			milestoneIDs[m.ID] = id
+			continue
+		}
+		id, err := u.CreateMilestone(ctx, m)
+		if err != nil {
+			return nil, fmt.Errorf("issues: error creating milestone %q: %w", m.Title, err)
+		}
+		milestoneIDs[m.ID] = id
+	}
+	return milestoneIDs, nil
+}
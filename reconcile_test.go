@@ -0,0 +1,115 @@
+// Copyright 2018 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package issues
+
+import (
+	"context"
+	"testing"
+)
+
+// reconcileFakeDownloader is a minimal Downloader for exercising Reconcile.
+type reconcileFakeDownloader struct {
+	issues     []Issue
+	labels     []Label
+	milestones []Milestone
+}
+
+func (d *reconcileFakeDownloader) ListIssues(ctx context.Context) ([]Issue, error) {
+	return d.issues, nil
+}
+func (d *reconcileFakeDownloader) ListComments(ctx context.Context, issueID int) ([]Comment, error) {
+	return nil, nil
+}
+func (d *reconcileFakeDownloader) ListLabels(ctx context.Context) ([]Label, error) {
+	return d.labels, nil
+}
+func (d *reconcileFakeDownloader) ListMilestones(ctx context.Context) ([]Milestone, error) {
+	return d.milestones, nil
+}
+func (d *reconcileFakeDownloader) ListAttachments(ctx context.Context, issueID int) ([]Attachment, error) {
+	return nil, nil
+}
+
+// reconcileFakeUploader is a minimal Uploader that also implements
+// LabelLister and MilestoneLister, recording what Reconcile actually
+// creates so tests can assert it skipped anything already present.
+type reconcileFakeUploader struct {
+	existingLabels     []Label
+	existingMilestones []Milestone
+
+	createdLabels     []string
+	createdMilestones []string
+
+	nextMilestoneID int
+}
+
+func (u *reconcileFakeUploader) CreateIssue(ctx context.Context, issue Issue) (int, error) {
+	return 0, nil
+}
+func (u *reconcileFakeUploader) CreateComment(ctx context.Context, issueID int, comment Comment) error {
+	return nil
+}
+func (u *reconcileFakeUploader) CreateLabel(ctx context.Context, label Label) error {
+	u.createdLabels = append(u.createdLabels, label.Name)
+	return nil
+}
+func (u *reconcileFakeUploader) CreateMilestone(ctx context.Context, milestone Milestone) (int, error) {
+	u.createdMilestones = append(u.createdMilestones, milestone.Title)
+	u.nextMilestoneID++
+	return u.nextMilestoneID, nil
+}
+func (u *reconcileFakeUploader) CreateAttachment(ctx context.Context, issueID int, attachment Attachment) (string, error) {
+	return "", nil
+}
+func (u *reconcileFakeUploader) ListLabels(ctx context.Context) ([]Label, error) {
+	return u.existingLabels, nil
+}
+func (u *reconcileFakeUploader) ListMilestones(ctx context.Context) ([]Milestone, error) {
+	return u.existingMilestones, nil
+}
+
+func TestReconcileSkipsExistingLabelsAndMilestones(t *testing.T) {
+	d := &reconcileFakeDownloader{
+		issues: []Issue{
+			{ID: 1, Labels: []string{"bug", "enhancement"}},
+		},
+		labels:     []Label{{Name: "wontfix", Color: "ffffff"}},
+		milestones: []Milestone{{ID: 10, Title: "v1"}, {ID: 20, Title: "v2"}},
+	}
+	u := &reconcileFakeUploader{
+		existingLabels:     []Label{{Name: "bug", Color: "ff0000"}},
+		existingMilestones: []Milestone{{ID: 99, Title: "v1"}},
+	}
+
+	milestoneIDs, err := Reconcile(context.Background(), d, u, nil)
+	if err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+
+	for _, name := range u.createdLabels {
+		if name == "bug" {
+			t.Errorf("Reconcile() recreated label %q that already existed", name)
+		}
+	}
+	wantCreated := map[string]bool{"enhancement": true, "wontfix": true}
+	if len(u.createdLabels) != len(wantCreated) {
+		t.Errorf("Reconcile() created labels %v, want %v", u.createdLabels, wantCreated)
+	}
+	for _, name := range u.createdLabels {
+		if !wantCreated[name] {
+			t.Errorf("Reconcile() created unexpected label %q", name)
+		}
+	}
+
+	if len(u.createdMilestones) != 1 || u.createdMilestones[0] != "v2" {
+		t.Errorf("Reconcile() created milestones %v, want [v2]", u.createdMilestones)
+	}
+	if id, ok := milestoneIDs[10]; !ok || id != 99 {
+		t.Errorf("Reconcile() milestoneIDs[10] = %d, %v, want 99, true", id, ok)
+	}
+	if _, ok := milestoneIDs[20]; !ok {
+		t.Errorf("Reconcile() is missing a mapping for the newly created milestone")
+	}
+}
@@ -0,0 +1,325 @@
+// Copyright 2018 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package issues // import "mellium.im/issues"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Exporter writes issues out to a destination that isn't itself an issue
+// tracker, such as a Bitbucket-shaped export file. It's the write-side
+// counterpart to Downloader used by the bidirectional export flow.
+type Exporter interface {
+	// ExportIssues writes issues to the destination, returning the ID each
+	// issue was given there.
+	ExportIssues(ctx context.Context, issues []Issue) ([]int, error)
+}
+
+// IncrementalDownloader is implemented by Downloaders that can list only
+// the issues created or updated since a given time. Syncer uses this to
+// avoid re-fetching and re-filtering every issue on every run; Downloaders
+// that don't implement it are still supported by ImportAll and ExportAll,
+// which fall back to listing everything and filtering by Issue.UpdatedOn
+// and Issue.CreatedOn.
+type IncrementalDownloader interface {
+	Downloader
+	ListIssuesSince(ctx context.Context, since time.Time) ([]Issue, error)
+}
+
+// State tracks the progress of a bidirectional migration between a source
+// and a destination, so that repeated runs are incremental instead of
+// reprocessing every issue from scratch.
+//
+// A State's exported fields are only safe to read and write through its
+// methods: ImportAll and ExportAll mutate it from a background goroutine
+// while a caller may concurrently call Save to checkpoint progress.
+type State struct {
+	mu sync.Mutex
+
+	// IDs maps source issue IDs to destination issue IDs.
+	IDs map[int]int `json:"ids"`
+	// LastSync is the latest UpdatedOn among the issues of the last run
+	// that finished without errors.
+	LastSync time.Time `json:"last_sync"`
+}
+
+// dstID returns the destination ID srcID was already synced to, if any.
+func (s *State) dstID(srcID int) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, ok := s.IDs[srcID]
+	return id, ok
+}
+
+// setDstID records that srcID was synced to dstID.
+func (s *State) setDstID(srcID, dstID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.IDs == nil {
+		s.IDs = make(map[int]int)
+	}
+	s.IDs[srcID] = dstID
+}
+
+// advance moves s.LastSync forward to the latest UpdatedOn across issues. It
+// never moves it backwards: an issue whose UpdatedOn is older than the
+// previous sync leaves LastSync unchanged.
+func (s *State) advance(issues []Issue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, issue := range issues {
+		if issue.UpdatedOn.After(s.LastSync) {
+			s.LastSync = issue.UpdatedOn
+		}
+	}
+}
+
+// LoadState reads a State from path. If path does not exist, an empty
+// State is returned instead of an error so that the first run of a sync
+// doesn't require the file to already be present.
+func LoadState(path string) (*State, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return &State{IDs: make(map[int]int)}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("issues: error opening state file %q: %w", path, err)
+	}
+	defer f.Close()
+
+	state := &State{}
+	if err := json.NewDecoder(f).Decode(state); err != nil {
+		return nil, fmt.Errorf("issues: error decoding state file %q: %w", path, err)
+	}
+	if state.IDs == nil {
+		state.IDs = make(map[int]int)
+	}
+	return state, nil
+}
+
+// Save writes state to path as JSON, overwriting any existing file.
+func (s *State) Save(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("issues: error creating state file %q: %w", path, err)
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(s); err != nil {
+		return fmt.Errorf("issues: error encoding state file %q: %w", path, err)
+	}
+	return nil
+}
+
+// ExportStatus describes what happened to a single issue during a Syncer
+// run.
+type ExportStatus int
+
+// The possible values of ExportStatus.
+const (
+	// StatusCreated means the issue didn't exist at the destination yet
+	// and was created.
+	StatusCreated ExportStatus = iota
+	// StatusUpdated means the issue already existed at the destination and
+	// was updated in place.
+	StatusUpdated
+	// StatusNothingToDo means the issue was already up to date.
+	StatusNothingToDo
+	// StatusError means processing the issue failed; Err on the
+	// ExportResult holds the reason.
+	StatusError
+)
+
+func (s ExportStatus) String() string {
+	switch s {
+	case StatusCreated:
+		return "Created"
+	case StatusUpdated:
+		return "Updated"
+	case StatusNothingToDo:
+		return "NothingToDo"
+	case StatusError:
+		return "Error"
+	default:
+		return "Unknown"
+	}
+}
+
+// ExportResult is the outcome of syncing a single issue.
+type ExportResult struct {
+	// SourceID is the issue's ID on the source tracker.
+	SourceID int
+	// DestID is the issue's ID on the destination, if it has one yet.
+	DestID int
+	Status ExportStatus
+	Err    error
+}
+
+// Syncer drives an incremental, resumable migration between a Downloader
+// and either an Uploader or an Exporter, recording progress in a State so
+// that later runs only need to process what changed.
+type Syncer struct {
+	Downloader Downloader
+	// Uploader is used by ImportAll. It may be nil if only ExportAll will
+	// be called.
+	Uploader Uploader
+	// Exporter is used by ExportAll. It may be nil if only ImportAll will
+	// be called.
+	Exporter Exporter
+	State    *State
+
+	// MilestoneIDs maps source milestone IDs to their destination
+	// milestone number, as returned by Reconcile. If nil, an issue's
+	// milestone ID is uploaded unchanged.
+	MilestoneIDs map[int]int
+}
+
+// NewSyncer creates a Syncer backed by state.
+func NewSyncer(d Downloader, state *State) *Syncer {
+	return &Syncer{Downloader: d, State: state}
+}
+
+// sourceIssuesSince lists every issue from s.Downloader that was created or
+// updated since since, using ListIssuesSince when the Downloader supports
+// it.
+func (s *Syncer) sourceIssuesSince(ctx context.Context, since time.Time) ([]Issue, error) {
+	if d, ok := s.Downloader.(IncrementalDownloader); ok {
+		return d.ListIssuesSince(ctx, since)
+	}
+	all, err := s.Downloader.ListIssues(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var recent []Issue
+	for _, issue := range all {
+		if issue.UpdatedOn.After(since) || issue.CreatedOn.After(since) {
+			recent = append(recent, issue)
+		}
+	}
+	return recent, nil
+}
+
+// ImportAll imports every issue on the source tracker created or updated
+// since since into s.Uploader, skipping ones that were already imported
+// according to s.State and recording the ones it creates.
+//
+// The returned channel is closed once every issue has been processed; a
+// caller that doesn't want to wait for the whole run can read from it in
+// a separate goroutine. s.State.LastSync is only advanced if no issue
+// failed, so failed issues are listed again by the next run.
+func (s *Syncer) ImportAll(ctx context.Context, since time.Time) (<-chan ExportResult, error) {
+	if s.Uploader == nil {
+		return nil, fmt.Errorf("issues: Syncer has no Uploader configured")
+	}
+	srcIssues, err := s.sourceIssuesSince(ctx, since)
+	if err != nil {
+		return nil, fmt.Errorf("issues: error listing issues since %s: %w", since, err)
+	}
+
+	results := make(chan ExportResult, len(srcIssues))
+	go func() {
+		defer close(results)
+		failed := false
+		for _, issue := range srcIssues {
+			if dstID, ok := s.State.dstID(issue.ID); ok {
+				results <- ExportResult{SourceID: issue.ID, DestID: dstID, Status: StatusNothingToDo}
+				continue
+			}
+
+			issue.Comments, err = s.Downloader.ListComments(ctx, issue.ID)
+			if err != nil {
+				failed = true
+				results <- ExportResult{SourceID: issue.ID, Status: StatusError, Err: err}
+				continue
+			}
+			issue.Attachments, err = s.Downloader.ListAttachments(ctx, issue.ID)
+			if err != nil {
+				failed = true
+				results <- ExportResult{SourceID: issue.ID, Status: StatusError, Err: err}
+				continue
+			}
+			if issue.Milestone != nil {
+				if id, ok := s.MilestoneIDs[issue.Milestone.ID]; ok {
+					issue.Milestone.ID = id
+				}
+			}
+
+			dstID, err := s.Uploader.CreateIssue(ctx, issue)
+			if err != nil {
+				failed = true
+				results <- ExportResult{SourceID: issue.ID, Status: StatusError, Err: err}
+				continue
+			}
+			s.State.setDstID(issue.ID, dstID)
+			results <- ExportResult{SourceID: issue.ID, DestID: dstID, Status: StatusCreated}
+		}
+		if !failed {
+			s.State.advance(srcIssues)
+		}
+	}()
+	return results, nil
+}
+
+// ExportAll exports every issue on the source tracker created or updated
+// since since to s.Exporter, skipping ones that were already exported
+// according to s.State and recording the ones it creates.
+func (s *Syncer) ExportAll(ctx context.Context, since time.Time) (<-chan ExportResult, error) {
+	if s.Exporter == nil {
+		return nil, fmt.Errorf("issues: Syncer has no Exporter configured")
+	}
+	srcIssues, err := s.sourceIssuesSince(ctx, since)
+	if err != nil {
+		return nil, fmt.Errorf("issues: error listing issues since %s: %w", since, err)
+	}
+
+	var toExport []Issue
+	for _, issue := range srcIssues {
+		if _, ok := s.State.dstID(issue.ID); ok {
+			continue
+		}
+		issue.Comments, err = s.Downloader.ListComments(ctx, issue.ID)
+		if err != nil {
+			return nil, fmt.Errorf("issues: error listing comments for issue %d: %w", issue.ID, err)
+		}
+		toExport = append(toExport, issue)
+	}
+
+	results := make(chan ExportResult, len(srcIssues))
+	go func() {
+		defer close(results)
+		for _, issue := range srcIssues {
+			if dstID, ok := s.State.dstID(issue.ID); ok {
+				results <- ExportResult{SourceID: issue.ID, DestID: dstID, Status: StatusNothingToDo}
+			}
+		}
+
+		if len(toExport) == 0 {
+			s.State.advance(srcIssues)
+			return
+		}
+		dstIDs, err := s.Exporter.ExportIssues(ctx, toExport)
+		if err != nil {
+			for _, issue := range toExport {
+				results <- ExportResult{SourceID: issue.ID, Status: StatusError, Err: err}
+			}
+			return
+		}
+		for i, issue := range toExport {
+			s.State.setDstID(issue.ID, dstIDs[i])
+			results <- ExportResult{SourceID: issue.ID, DestID: dstIDs[i], Status: StatusCreated}
+		}
+		s.State.advance(srcIssues)
+	}()
+	return results, nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2018 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package issues
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// syncFakeDownloader is a minimal Downloader for exercising Syncer.
+type syncFakeDownloader struct {
+	issues []Issue
+}
+
+func (d *syncFakeDownloader) ListIssues(ctx context.Context) ([]Issue, error) { return d.issues, nil }
+func (d *syncFakeDownloader) ListComments(ctx context.Context, issueID int) ([]Comment, error) {
+	return nil, nil
+}
+func (d *syncFakeDownloader) ListLabels(ctx context.Context) ([]Label, error) { return nil, nil }
+func (d *syncFakeDownloader) ListMilestones(ctx context.Context) ([]Milestone, error) {
+	return nil, nil
+}
+func (d *syncFakeDownloader) ListAttachments(ctx context.Context, issueID int) ([]Attachment, error) {
+	return nil, nil
+}
+
+// syncFakeUploader is a minimal Uploader that records which issues it was
+// asked to create, so tests can assert Syncer skipped the rest.
+type syncFakeUploader struct {
+	created []int
+	nextID  int
+}
+
+func (u *syncFakeUploader) CreateIssue(ctx context.Context, issue Issue) (int, error) {
+	u.created = append(u.created, issue.ID)
+	u.nextID++
+	return u.nextID, nil
+}
+func (u *syncFakeUploader) CreateComment(ctx context.Context, issueID int, comment Comment) error {
+	return nil
+}
+func (u *syncFakeUploader) CreateLabel(ctx context.Context, label Label) error { return nil }
+func (u *syncFakeUploader) CreateMilestone(ctx context.Context, milestone Milestone) (int, error) {
+	return 0, nil
+}
+func (u *syncFakeUploader) CreateAttachment(ctx context.Context, issueID int, attachment Attachment) (string, error) {
+	return "", nil
+}
+
+func drain(results <-chan ExportResult) []ExportResult {
+	var all []ExportResult
+	for r := range results {
+		all = append(all, r)
+	}
+	return all
+}
+
+func TestSyncerImportAllSkipsAlreadySynced(t *testing.T) {
+	d := &syncFakeDownloader{issues: []Issue{
+		{ID: 1, UpdatedOn: time.Unix(100, 0)},
+		{ID: 2, UpdatedOn: time.Unix(200, 0)},
+	}}
+	u := &syncFakeUploader{}
+	state := &State{IDs: map[int]int{1: 101}}
+	syncer := &Syncer{Downloader: d, Uploader: u, State: state}
+
+	results, err := syncer.ImportAll(context.Background(), time.Time{})
+	if err != nil {
+		t.Fatalf("ImportAll() = %v", err)
+	}
+	all := drain(results)
+
+	if len(u.created) != 1 || u.created[0] != 2 {
+		t.Fatalf("ImportAll() created issues %v, want [2]", u.created)
+	}
+
+	var sawSkip, sawCreate bool
+	for _, r := range all {
+		switch r.SourceID {
+		case 1:
+			sawSkip = true
+			if r.Status != StatusNothingToDo || r.DestID != 101 {
+				t.Errorf("result for already-synced issue 1 = %+v, want Status=NothingToDo DestID=101", r)
+			}
+		case 2:
+			sawCreate = true
+			if r.Status != StatusCreated {
+				t.Errorf("result for new issue 2 = %+v, want Status=Created", r)
+			}
+		}
+	}
+	if !sawSkip || !sawCreate {
+		t.Fatalf("ImportAll() results %+v missing expected issues", all)
+	}
+
+	if dstID, ok := state.dstID(2); !ok || dstID != u.nextID {
+		t.Errorf("state.dstID(2) = %d, %v, want %d, true", dstID, ok, u.nextID)
+	}
+	if !state.LastSync.Equal(time.Unix(200, 0)) {
+		t.Errorf("state.LastSync = %v, want %v", state.LastSync, time.Unix(200, 0))
+	}
+}
+
+func TestSyncerImportAllResumesFromState(t *testing.T) {
+	d := &syncFakeDownloader{issues: []Issue{
+		{ID: 1, UpdatedOn: time.Unix(100, 0)},
+	}}
+	u := &syncFakeUploader{}
+	state := &State{IDs: map[int]int{1: 42}}
+	syncer := &Syncer{Downloader: d, Uploader: u, State: state}
+
+	results, err := syncer.ImportAll(context.Background(), time.Time{})
+	if err != nil {
+		t.Fatalf("ImportAll() = %v", err)
+	}
+	all := drain(results)
+
+	if len(u.created) != 0 {
+		t.Fatalf("ImportAll() created issues %v, want none", u.created)
+	}
+	if len(all) != 1 || all[0].Status != StatusNothingToDo || all[0].DestID != 42 {
+		t.Fatalf("ImportAll() results = %+v, want a single NothingToDo result with DestID 42", all)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2018 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package issues // import "mellium.im/issues"
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// UserMapping is a single entry in a UserMap, describing the GitHub
+// identity a source tracker username should be translated to.
+type UserMapping struct {
+	GitHub string `yaml:"github"`
+	Email  string `yaml:"email"`
+}
+
+// UserMap maps source tracker usernames (for example, Bitbucket reporters)
+// to their GitHub identity, so that assignees, @mentions, and comment
+// attribution can point at a real GitHub user instead of a name that only
+// made sense on the old tracker.
+type UserMap struct {
+	entries map[string]UserMapping
+
+	// Unmapped collects every username that was looked up but had no
+	// GitHub entry, so a run can report them once it finishes.
+	Unmapped map[string]bool
+}
+
+// LoadUserMap reads a UserMap from the YAML file at path, shaped like:
+//
+//	reporter1:
+//	  github: octocat
+//	  email: octocat@example.com
+func LoadUserMap(path string) (*UserMap, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("issues: error reading user map %q: %w", path, err)
+	}
+	m := &UserMap{}
+	if err := yaml.Unmarshal(b, &m.entries); err != nil {
+		return nil, fmt.Errorf("issues: error parsing user map %q: %w", path, err)
+	}
+	return m, nil
+}
+
+// GitHub returns the GitHub handle user is mapped to. If user has no entry
+// (or its entry has no GitHub handle), GitHub returns false and records
+// user in m.Unmapped.
+func (m *UserMap) GitHub(user string) (string, bool) {
+	if e, ok := m.entries[user]; ok && e.GitHub != "" {
+		return e.GitHub, true
+	}
+	if m.Unmapped == nil {
+		m.Unmapped = make(map[string]bool)
+	}
+	m.Unmapped[user] = true
+	return "", false
+}
+
+// UnmappedUsers returns the sorted list of usernames that were looked up
+// through GitHub but had no mapping.
+func (m *UserMap) UnmappedUsers() []string {
+	list := make([]string, 0, len(m.Unmapped))
+	for user := range m.Unmapped {
+		list = append(list, user)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// mentionPattern matches an @mention using the characters both Bitbucket
+// and GitHub allow in a username. The leading group requires the @ to be at
+// the start of body or preceded by something other than a word character,
+// @, or ., so it doesn't match mid-token inside an email address like
+// joe@example.com. Go's regexp package has no lookbehind assertion, so the
+// boundary character is captured and re-emitted instead.
+var mentionPattern = regexp.MustCompile(`(^|[^\w@.])@([\w-]+)`)
+
+// RewriteMentions rewrites every @mention in body from a source tracker
+// username to the GitHub handle m maps it to, leaving unmapped mentions
+// untouched. A nil m leaves body untouched entirely.
+func RewriteMentions(body string, m *UserMap) string {
+	if m == nil {
+		return body
+	}
+	return mentionPattern.ReplaceAllStringFunc(body, func(mention string) string {
+		sub := mentionPattern.FindStringSubmatch(mention)
+		boundary, user := sub[1], sub[2]
+		handle, ok := m.GitHub(user)
+		if !ok {
+			return mention
+		}
+		return boundary + "@" + handle
+	})
+}
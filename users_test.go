@@ -0,0 +1,64 @@
+// Copyright 2018 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package issues
+
+import "testing"
+
+func TestRewriteMentions(t *testing.T) {
+	m := &UserMap{entries: map[string]UserMapping{
+		"alice": {GitHub: "alice-gh"},
+	}}
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "nil map leaves body untouched",
+			body: "cc @alice",
+			want: "cc @alice",
+		},
+		{
+			name: "mention at start of body",
+			body: "@alice can you take a look?",
+			want: "@alice-gh can you take a look?",
+		},
+		{
+			name: "mention mid-sentence",
+			body: "assigning to @alice for review",
+			want: "assigning to @alice-gh for review",
+		},
+		{
+			name: "unmapped mention is left alone",
+			body: "cc @bob",
+			want: "cc @bob",
+		},
+		{
+			name: "email address is not treated as a mention",
+			body: "contact alice@example.com for details",
+			want: "contact alice@example.com for details",
+		},
+		{
+			name: "mention after punctuation is rewritten",
+			body: "(@alice)",
+			want: "(@alice-gh)",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got string
+			if test.name == "nil map leaves body untouched" {
+				got = RewriteMentions(test.body, nil)
+			} else {
+				got = RewriteMentions(test.body, m)
+			}
+			if got != test.want {
+				t.Errorf("RewriteMentions(%q) = %q, want %q", test.body, got, test.want)
+			}
+		})
+	}
+}